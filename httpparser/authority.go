@@ -0,0 +1,47 @@
+package httpparser
+
+import "bytes"
+
+/*
+	Target of CONNECT request is in authority-form: host and port, separated by colon.
+	Host may be an IPv6 literal in square brackets, port is obligatory
+*/
+func parseAuthority(target []byte) (host, port []byte, err error) {
+	if host, port, err = splitHostPort(target); err == nil && len(port) == 0 {
+		err = ErrInvalidAuthority
+	}
+
+	return host, port, err
+}
+
+/*
+	Splits authority (without userinfo) into host and port. Port is optional, so it
+	is empty in case authority has none
+*/
+func splitHostPort(authority []byte) (host, port []byte, err error) {
+	host = authority
+
+	// colon inside an IPv6 literal doesn't separate the port
+	if colon := bytes.LastIndexByte(authority, ':'); colon > bytes.LastIndexByte(authority, ']') {
+		host, port = authority[:colon], authority[colon+1:]
+	}
+
+	if len(host) > 1 && host[0] == '[' && host[len(host)-1] == ']' {
+		host = host[1 : len(host)-1]
+	} else if bytes.IndexByte(host, ':') != -1 {
+		// unbracketed IPv6 address
+		return nil, nil, ErrInvalidAuthority
+	}
+
+	if len(host) == 0 || bytes.ContainsAny(host, "[]/@?#") {
+		return nil, nil, ErrInvalidAuthority
+	}
+
+	for _, char := range port {
+		if char < '0' || char > '9' {
+			return nil, nil, ErrInvalidAuthority
+		}
+	}
+
+	return host, port, nil
+}
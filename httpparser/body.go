@@ -0,0 +1,349 @@
+package httpparser
+
+var (
+	closeConnection = []byte("close")
+	keepAlive       = []byte("keep-alive")
+	upgrade         = []byte("upgrade")
+)
+
+type transferCoding uint8
+
+const (
+	codingChunked transferCoding = iota
+	codingCompress
+	codingDeflate
+	codingGzip
+	codingXCompress
+	codingXGzip
+)
+
+// indexed by transferCoding
+var transferCodings = [][]byte{
+	[]byte("chunked"), []byte("compress"), []byte("deflate"), []byte("gzip"), []byte("x-compress"), []byte("x-gzip"),
+}
+
+/*
+	Keeps everything that is needed to find out where the body of the message ends,
+	and pushes body pieces to the callback. Embedded by both requests and responses
+	parsers
+*/
+type bodyParser struct {
+	onBody           OnBodyCallback
+	onTransferCoding OnTransferCodingCallback
+	chunksParser     *chunkedBodyParser
+
+	bodyBytesLeft       int
+	hasContentLength    bool
+	hasTransferEncoding bool
+	transferCodings     []transferCoding
+	isChunked           bool
+	// tokens of Connection header
+	closeConnection     bool
+	keepAliveConnection bool
+	upgradeConnection   bool
+	// framing of the message is ambiguous, so connection must be closed after it
+	forceClose bool
+	// body is received, but not passed to the callback
+	discardBody bool
+
+	strictFraming bool
+	maxBodyLength int
+}
+
+type OnTransferCodingCallback func([]byte) error
+
+type bodyProtocol interface {
+	OnBody([]byte) error
+}
+
+/*
+	Optional callbacks of chunked body (trailers and chunk extensions) are taken from
+	the protocol in case it implements them
+*/
+func newBodyParser(protocol bodyProtocol, settings Settings) bodyParser {
+	var (
+		onTrailer        OnHeaderCallback
+		onExtension      OnChunkExtensionCallback
+		onTransferCoding OnTransferCodingCallback
+	)
+
+	if codings, ok := protocol.(TransferCodingsProtocol); ok {
+		onTransferCoding = codings.OnTransferCoding
+	}
+	if trailers, ok := protocol.(TrailersProtocol); ok {
+		onTrailer = trailers.OnTrailer
+	}
+	if extensions, ok := protocol.(ChunkExtensionsProtocol); ok {
+		onExtension = extensions.OnChunkExtension
+	}
+
+	return bodyParser{
+		onBody:           protocol.OnBody,
+		onTransferCoding: onTransferCoding,
+		chunksParser:     newChunkedBodyParser(protocol.OnBody, onTrailer, onExtension, settings),
+		strictFraming:    settings.StrictFraming,
+		maxBodyLength:    settings.MaxBodyLength,
+	}
+}
+
+func (b *bodyParser) Clear() {
+	b.bodyBytesLeft = 0
+	b.hasContentLength = false
+	b.hasTransferEncoding = false
+	b.transferCodings = b.transferCodings[:0]
+	b.isChunked = false
+	b.closeConnection = false
+	b.keepAliveConnection = false
+	b.upgradeConnection = false
+	b.forceClose = false
+	b.discardBody = false
+	b.chunksParser.discardBody = false
+}
+
+func (b *bodyParser) skipBody() {
+	b.discardBody = true
+	b.chunksParser.discardBody = true
+}
+
+/*
+	Looks for headers that are responsible for body framing. Must be called for
+	every header of the message
+*/
+func (b *bodyParser) inspectHeader(id HeaderID, value []byte) error {
+	switch id {
+	case HeaderContentLength:
+		length, err := parseContentLengthList(value)
+
+		if err != nil {
+			return err
+		}
+
+		if b.hasContentLength && (b.strictFraming || length != b.bodyBytesLeft) {
+			return ErrDuplicateContentLength
+		}
+
+		// rejected before the body begins
+		if length > b.maxBodyLength {
+			return ErrBodyTooBig
+		}
+
+		b.bodyBytesLeft = length
+		b.hasContentLength = true
+
+		return b.checkFraming()
+	case HeaderTransferEncoding:
+		if err := b.parseTransferEncoding(value); err != nil {
+			return err
+		}
+
+		return b.checkFraming()
+	case HeaderConnection:
+		b.parseConnection(value)
+	}
+
+	return nil
+}
+
+/*
+	Connection is a list of tokens, that may be split into a few headers. Tokens
+	we are not interested in are ignored
+*/
+func (b *bodyParser) parseConnection(value []byte) {
+	var token []byte
+
+	for len(value) > 0 {
+		token, value = cutListElement(value)
+
+		switch {
+		case EqualFold(closeConnection, token):
+			b.closeConnection = true
+		case EqualFold(keepAlive, token):
+			b.keepAliveConnection = true
+		case EqualFold(upgrade, token):
+			b.upgradeConnection = true
+		}
+	}
+}
+
+/*
+	Returns whether connection may be reused after the message. Messages of HTTP/1.1
+	are persistent by default, but HTTP/1.0 ones - only with keep-alive token
+*/
+func (b *bodyParser) keepAlive(persistentByDefault bool) bool {
+	switch {
+	case b.forceClose, b.closeConnection:
+		return false
+	case b.keepAliveConnection:
+		return true
+	default:
+		return persistentByDefault
+	}
+}
+
+/*
+	HTTP/1.1 is the only supported protocol whose connections are persistent by default
+*/
+func isPersistentByDefault(proto []byte) bool {
+	return len(proto) > 3 && string(proto[len(proto)-3:]) == "1.1"
+}
+
+/*
+	Message that has both Content-Length and Transfer-Encoding is the classic way of
+	requests smuggling. In strict mode it is rejected, otherwise Transfer-Encoding
+	wins, but connection must be closed after the message anyway
+*/
+func (b *bodyParser) checkFraming() error {
+	if !b.hasContentLength || !b.hasTransferEncoding {
+		return nil
+	}
+
+	if b.strictFraming {
+		return ErrConflictingFraming
+	}
+
+	b.forceClose = true
+
+	return nil
+}
+
+/*
+	Transfer-Encoding is a list of codings, that may be split into a few headers. Message
+	has chunked body only in case chunked is the final coding, and it is applied only once
+*/
+func (b *bodyParser) parseTransferEncoding(value []byte) error {
+	b.hasTransferEncoding = true
+
+	var element []byte
+
+	for len(value) > 0 {
+		if element, value = cutListElement(value); len(element) == 0 {
+			continue
+		}
+
+		coding, found := lookupTransferCoding(element)
+
+		if !found {
+			return ErrUnsupportedTransferEncoding
+		}
+
+		// chunked must be the final coding, and must be applied only once
+		b.isChunked = coding == codingChunked && !b.hasChunkedCoding()
+		b.transferCodings = append(b.transferCodings, coding)
+	}
+
+	return nil
+}
+
+func (b *bodyParser) hasChunkedCoding() bool {
+	for _, coding := range b.transferCodings {
+		if coding == codingChunked {
+			return true
+		}
+	}
+
+	return false
+}
+
+func lookupTransferCoding(name []byte) (coding transferCoding, found bool) {
+	for i, known := range transferCodings {
+		if EqualFold(known, name) {
+			return transferCoding(i), true
+		}
+	}
+
+	return 0, false
+}
+
+/*
+	Passes codings that were applied before chunked one to the callback, in the
+	order they were applied, starting from the one at index from. Must be called
+	once all the headers are received. Stops as soon as the callback returns an
+	error (ErrPause as well), returning the index of the coding to continue from
+*/
+func (b *bodyParser) pushTransferCodings(from int) (next int, err error) {
+	if b.onTransferCoding == nil || !b.isChunked {
+		return from, nil
+	}
+
+	codings := b.transferCodings[:len(b.transferCodings)-1]
+
+	for next = from; next < len(codings); {
+		err = b.onTransferCoding(transferCodings[codings[next]])
+		next++
+
+		if err != nil {
+			return next, err
+		}
+	}
+
+	return next, nil
+}
+
+/*
+	Content-Length may be a comma-separated list of values in case some intermediary
+	has joined duplicated headers. That's fine only if all the values are identical
+*/
+func parseContentLengthList(value []byte) (length int, err error) {
+	var element []byte
+
+	for i := 0; ; i++ {
+		element, value = cutListElement(value)
+
+		var num int
+
+		if num, err = ParseContentLength(element); err != nil {
+			return 0, err
+		}
+
+		if i > 0 && num != length {
+			return 0, ErrDuplicateContentLength
+		}
+
+		length = num
+
+		if value == nil {
+			return length, nil
+		}
+	}
+}
+
+func (b *bodyParser) pushBodyPiece(data []byte) (done bool, extra []byte, err error) {
+	if b.isChunked {
+		done, extra, err = b.chunksParser.Feed(data)
+
+		return done, extra, err
+	}
+
+	dataLen := len(data)
+
+	// in case callback returns ErrPause, the piece is pushed anyway, so it is
+	// returned along with the usual results
+	if b.bodyBytesLeft > dataLen {
+		if err = b.pushBody(data); err != nil && err != ErrPause {
+			return true, nil, err
+		}
+
+		b.bodyBytesLeft -= dataLen
+
+		return false, nil, err
+	}
+
+	if b.bodyBytesLeft <= 0 {
+		// already?? Looks like a bug
+		return true, data, nil
+	}
+
+	if err = b.pushBody(data[:b.bodyBytesLeft]); err != nil && err != ErrPause {
+		return true, nil, err
+	}
+
+	return true, data[b.bodyBytesLeft:], err
+}
+
+func (b *bodyParser) pushBody(piece []byte) error {
+	if b.discardBody {
+		return nil
+	}
+
+	return b.onBody(piece)
+}
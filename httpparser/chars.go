@@ -0,0 +1,116 @@
+package httpparser
+
+import "bytes"
+
+/*
+	Characters that are allowed in tokens (RFC 9110, section 5.6.2). Tokens are
+	everywhere: methods, header names, transfer codings, chunk extensions, etc.
+*/
+var tokenChars = func() (table [256]bool) {
+	for char := '0'; char <= '9'; char++ {
+		table[char] = true
+	}
+	for char := 'a'; char <= 'z'; char++ {
+		table[char] = true
+		table[char-0x20] = true
+	}
+	for _, char := range []byte("!#$%&'*+-.^_`|~") {
+		table[char] = true
+	}
+
+	return table
+}()
+
+// value of the hex digit, or -1 in case the character isn't a hex digit
+var hexValues = func() (table [256]int8) {
+	for i := range table {
+		table[i] = -1
+	}
+	for char := '0'; char <= '9'; char++ {
+		table[char] = int8(char - '0')
+	}
+	for char := 'a'; char <= 'f'; char++ {
+		table[char] = int8(char-'a') + 10
+		table[char-0x20] = int8(char-'a') + 10
+	}
+
+	return table
+}()
+
+func isTokenChar(char byte) bool {
+	return tokenChars[char]
+}
+
+func isToken(data []byte) bool {
+	if len(data) == 0 {
+		return false
+	}
+
+	for _, char := range data {
+		if !isTokenChar(char) {
+			return false
+		}
+	}
+
+	return true
+}
+
+/*
+	HTTP-version = HTTP-name "/" DIGIT "." DIGIT, but lower-cased name is accepted too
+*/
+func isProtocolChar(char byte) bool {
+	lower := char | 0x20
+
+	return lower >= 'a' && lower <= 'z' || char >= '0' && char <= '9' || char == '/' || char == '.'
+}
+
+func isWhitespace(char byte) bool {
+	return char == ' ' || char == '\t'
+}
+
+func trimWhitespace(data []byte) []byte {
+	for len(data) > 0 && isWhitespace(data[0]) {
+		data = data[1:]
+	}
+	for len(data) > 0 && isWhitespace(data[len(data)-1]) {
+		data = data[:len(data)-1]
+	}
+
+	return data
+}
+
+/*
+	Cuts the first element of comma-separated list off. Element is trimmed from
+	whitespaces and may be empty. Rest is nil in case there are no more elements
+*/
+func cutListElement(list []byte) (element, rest []byte) {
+	end := bytes.IndexByte(list, ',')
+
+	if end == -1 {
+		return trimWhitespace(list), nil
+	}
+
+	return trimWhitespace(list[:end]), list[end+1:]
+}
+
+/*
+	Case-insensitive comparison of ASCII data with the sample
+*/
+func equalFoldString(data []byte, sample string) bool {
+	if len(data) != len(sample) {
+		return false
+	}
+
+	for i := 0; i < len(data); i++ {
+		if data[i]|0x20 != sample[i]|0x20 {
+			return false
+		}
+
+		// only letters may differ in case
+		if data[i] != sample[i] && (data[i]|0x20 < 'a' || data[i]|0x20 > 'z') {
+			return false
+		}
+	}
+
+	return true
+}
@@ -1,29 +1,84 @@
 package httpparser
 
-type OnBodyCallback func([]byte) error
+type (
+	OnBodyCallback           func([]byte) error
+	OnChunkExtensionCallback func(name, value []byte) error
+)
 
 type chunkedBodyParser struct {
-	callback       OnBodyCallback
-	state          chunkedBodyState
-	chunkLength    int
-	chunkBodyBegin int
-
-	maxChunkSize int
+	callback        OnBodyCallback
+	state           chunkedBodyState
+	chunkLength     int
+	chunkSizeDigits int // leading zeroes included
+	chunkBodyBegin  int
+	trailersParser  *headersParser
+	discardBody     bool
+
+	onExtension         OnChunkExtensionCallback
+	extensionBuffer     []byte
+	extensionValueBegin int
+	extensionsLength    int
+	// total length of the chunks received so far
+	bodyLength int
+
+	maxChunkSize             int
+	maxChunkExtensionsLength int
+	maxBodyLength            int
+	strictness               Strictness
 }
 
 func NewChunkedBodyParser(callback OnBodyCallback, maxChunkSize int) *chunkedBodyParser {
+	return newChunkedBodyParser(callback, nil, nil, Settings{
+		MaxChunkLength:           maxChunkSize,
+		MaxBodyLength:            maxBodyLength,
+		MaxHeaderLineLength:      maxHeaderLineLength,
+		MaxHeadersCount:          maxHeadersCount,
+		MaxHeaderSectionSize:     maxHeaderSectionSize,
+		MaxChunkExtensionsLength: maxChunkExtensionsLength,
+	})
+}
+
+/*
+	Trailers are parsed by the same state machine as headers are, and are limited
+	in the same way. In case onTrailer or onExtension callback is nil, trailers or
+	chunk extensions respectively are just skipped
+*/
+func newChunkedBodyParser(
+	callback OnBodyCallback, onTrailer OnHeaderCallback, onExtension OnChunkExtensionCallback, settings Settings,
+) *chunkedBodyParser {
+	if onTrailer == nil {
+		onTrailer = func(_, _ []byte) error {
+			return nil
+		}
+	}
+	if onExtension == nil {
+		onExtension = func(_, _ []byte) error {
+			return nil
+		}
+	}
+
 	return &chunkedBodyParser{
 		callback: callback,
 		state:    chunkLength,
 		// as chunked requests aren't obligatory, we better keep the buffer unallocated until
 		// we'll need it
-		maxChunkSize: maxChunkSize,
+		trailersParser:           newHeadersParser(onTrailer, nil, settings),
+		onExtension:              onExtension,
+		maxChunkSize:             settings.MaxChunkLength,
+		maxChunkExtensionsLength: settings.MaxChunkExtensionsLength,
+		maxBodyLength:            settings.MaxBodyLength,
+		strictness:               settings.Strictness,
 	}
 }
 
 func (p *chunkedBodyParser) Clear() {
 	p.state = chunkLength
 	p.chunkLength = 0
+	p.chunkSizeDigits = 0
+	p.trailersParser.Clear()
+	p.extensionBuffer = p.extensionBuffer[:0]
+	p.extensionsLength = 0
+	p.bodyLength = 0
 }
 
 func (p *chunkedBodyParser) Feed(data []byte) (done bool, extraBytes []byte, err error) {
@@ -43,109 +98,159 @@ func (p *chunkedBodyParser) Feed(data []byte) (done bool, extraBytes []byte, err
 	for i, char := range data {
 		switch p.state {
 		case chunkLength:
+			if p.chunkSizeDigits == 0 && hexValues[char] == -1 {
+				// chunk size is empty, or is preceded by whitespaces
+				err = p.parseError(ErrInvalidChunkSize, data, i)
+				p.complete()
+
+				return true, nil, err
+			}
+
 			switch char {
 			case '\r':
 				p.state = chunkLengthCR
 			case '\n':
-				if p.chunkLength == 0 {
-					p.state = lastChunk
-					break
+				if p.strictness.has(RejectBareLF) {
+					err = p.parseError(ErrBareLF, data, i)
+					p.complete()
+
+					return true, nil, err
 				}
 
-				p.chunkBodyBegin = i + 1
-				p.state = chunkBody
+				p.chunkSizeLineEnd(i + 1)
+			case ';':
+				p.state = chunkExtensionName
+			case ' ', '\t':
+				p.state = chunkExtensionBegin
 			default:
-				// TODO: add support of trailers
-				if (char < '0' && char > '9') && (char < 'a' && char > 'f') && (char < 'A' && char > 'F') {
-					// non-printable ascii-character
+				digit := int(hexValues[char])
+
+				if digit == -1 {
+					err = p.parseError(ErrInvalidChunkSize, data, i)
+					p.complete()
+
+					return true, nil, err
+				}
+
+				// chunk size must not overflow even if maxChunkSize isn't reached yet
+				if p.chunkSizeDigits++; p.chunkSizeDigits > maxChunkSizeDigits || p.chunkLength > maxInt>>4 {
+					err = p.parseError(ErrTooBigChunkSize, data, i)
 					p.complete()
 
-					return true, nil, ErrInvalidChunkSize
+					return true, nil, err
 				}
 
-				p.chunkLength = (p.chunkLength << 4) + int((char&0xF)+9*(char>>6))
+				p.chunkLength = p.chunkLength<<4 | digit
 
 				if p.chunkLength > p.maxChunkSize {
+					err = p.parseError(ErrTooBigChunkSize, data, i)
 					p.complete()
 
-					return true, nil, ErrTooBigChunkSize
+					return true, nil, err
+				}
+
+				// rejected before the chunk begins
+				if p.bodyLength+p.chunkLength > p.maxBodyLength {
+					err = p.parseError(ErrBodyTooBig, data, i)
+					p.complete()
+
+					return true, nil, err
 				}
 			}
 		case chunkLengthCR:
 			if char != '\n' {
+				err = p.parseError(ErrInvalidChunkSplitter, data, i)
 				p.complete()
 
-				return true, nil, ErrInvalidChunkSplitter
+				return true, nil, err
 			}
 
-			if p.chunkLength == 0 {
-				p.state = lastChunk
-				break
+			p.chunkSizeLineEnd(i + 1)
+		case chunkExtensionBegin, chunkExtensionName, chunkExtensionNameEnd, chunkExtensionValue,
+			chunkExtensionTokenValue, chunkExtensionQuotedValue, chunkExtensionQuotedPair, chunkExtensionValueEnd:
+			if p.extensionsLength++; p.extensionsLength > p.maxChunkExtensionsLength {
+				err = p.parseError(ErrTooBigChunkExtensions, data, i)
+				p.complete()
+
+				return true, nil, err
 			}
 
-			p.chunkBodyBegin = i + 1
-			p.state = chunkBody
+			if err = p.feedExtension(char, i); err == ErrPause {
+				return p.pause(data[i+1:])
+			} else if err != nil {
+				if err == ErrInvalidChunkExtension || err == ErrBareLF {
+					err = p.parseError(err, data, i)
+				}
+
+				p.complete()
+
+				return true, nil, err
+			}
 		case chunkBody:
 			p.chunkLength--
 
 			if p.chunkLength == 0 {
+				// chunk may end exactly at the end of data, so push it right now
+				err = p.pushChunk(data[p.chunkBodyBegin : i+1])
 				p.state = chunkBodyEnd
-			}
-		case chunkBodyEnd:
-			if err = p.callback(data[p.chunkBodyBegin:i]); err != nil {
-				p.complete()
 
-				return true, nil, err
-			}
+				if err == ErrPause {
+					return p.pause(data[i+1:])
+				} else if err != nil {
+					p.complete()
 
+					return true, nil, err
+				}
+			}
+		case chunkBodyEnd:
 			switch char {
 			case '\r':
 				p.state = chunkBodyCR
 			case '\n':
+				if p.strictness.has(RejectBareLF) {
+					err = p.parseError(ErrBareLF, data, i)
+					p.complete()
+
+					return true, nil, err
+				}
+
 				p.state = chunkLength
 			default:
+				err = p.parseError(ErrInvalidChunkSplitter, data, i)
 				p.complete()
 
-				return true, nil, ErrInvalidChunkSplitter
+				return true, nil, err
 			}
 		case chunkBodyCR:
 			if char != '\n' {
+				err = p.parseError(ErrInvalidChunkSplitter, data, i)
 				p.complete()
 
-				return true, nil, ErrInvalidChunkSplitter
+				return true, nil, err
 			}
 
 			p.state = chunkLength
 		case lastChunk:
-			switch char {
-			case '\r':
-				p.state = lastChunkCR
-			case '\n':
-				p.complete()
+			done, extraBytes, err = p.trailersParser.Feed(data[i:])
+			p.chunkBodyBegin = 0
 
-				return true, data[i+1:], nil
-			default:
-				// looks sad, received everything, and fucked up in the end
-				// or this was made for special? Oh god
-				p.complete()
-
-				return true, nil, ErrInvalidChunkSplitter
+			if parseErr, ok := err.(*ParseError); ok {
+				parseErr.State = p.state.String()
+				parseErr.Offset += int64(i)
 			}
-		case lastChunkCR:
-			if char != '\n' {
-				p.complete()
 
-				return true, nil, ErrInvalidChunkSplitter
+			if done {
+				p.complete()
 			}
 
-			p.complete()
-
-			return true, data[i+1:], nil
+			return done, extraBytes, err
 		}
 	}
 
 	if p.state == chunkBody {
-		if err = p.callback(data[p.chunkBodyBegin:]); err != nil {
+		if err = p.pushChunk(data[p.chunkBodyBegin:]); err == ErrPause {
+			return p.pause(nil)
+		} else if err != nil {
 			p.complete()
 
 			return true, nil, err
@@ -157,6 +262,161 @@ func (p *chunkedBodyParser) Feed(data []byte) (done bool, extraBytes []byte, err
 	return false, nil, nil
 }
 
+/*
+	Chunk extensions grammar (RFC 9112, section 7.1.1):
+
+		chunk-ext      = *( BWS ";" BWS chunk-ext-name [ BWS "=" BWS chunk-ext-val ] )
+		chunk-ext-name = token
+		chunk-ext-val  = token / quoted-string
+
+	Every extension is pushed to the callback as soon as it is completed. Extension
+	without a value is pushed with an empty one
+*/
+func (p *chunkedBodyParser) feedExtension(char byte, i int) error {
+	switch p.state {
+	case chunkExtensionBegin:
+		switch {
+		case char == ';':
+			p.state = chunkExtensionName
+		case !isWhitespace(char):
+			return ErrInvalidChunkExtension
+		}
+	case chunkExtensionName:
+		switch {
+		case isTokenChar(char):
+			p.extensionBuffer = append(p.extensionBuffer, char)
+		case len(p.extensionBuffer) == 0:
+			if !isWhitespace(char) {
+				return ErrInvalidChunkExtension
+			}
+		case char == '=':
+			p.extensionValueBegin = len(p.extensionBuffer)
+			p.state = chunkExtensionValue
+		default:
+			p.extensionValueBegin = len(p.extensionBuffer)
+			p.state = chunkExtensionNameEnd
+
+			return p.feedExtension(char, i)
+		}
+	case chunkExtensionNameEnd:
+		if char == '=' {
+			p.state = chunkExtensionValue
+			break
+		}
+
+		return p.extensionEnd(char, i)
+	case chunkExtensionValue:
+		switch {
+		case char == '"':
+			p.state = chunkExtensionQuotedValue
+		case isTokenChar(char):
+			p.extensionBuffer = append(p.extensionBuffer, char)
+			p.state = chunkExtensionTokenValue
+		case !isWhitespace(char):
+			return ErrInvalidChunkExtension
+		}
+	case chunkExtensionTokenValue:
+		if isTokenChar(char) {
+			p.extensionBuffer = append(p.extensionBuffer, char)
+			break
+		}
+
+		p.state = chunkExtensionValueEnd
+
+		return p.extensionEnd(char, i)
+	case chunkExtensionQuotedValue:
+		switch {
+		case char == '"':
+			p.state = chunkExtensionValueEnd
+		case char == '\\':
+			p.state = chunkExtensionQuotedPair
+		case char == '\t', char >= ' ' && char != 0x7f:
+			p.extensionBuffer = append(p.extensionBuffer, char)
+		default:
+			return ErrInvalidChunkExtension
+		}
+	case chunkExtensionQuotedPair:
+		if char != '\t' && (char < ' ' || char == 0x7f) {
+			return ErrInvalidChunkExtension
+		}
+
+		p.extensionBuffer = append(p.extensionBuffer, char)
+		p.state = chunkExtensionQuotedValue
+	case chunkExtensionValueEnd:
+		return p.extensionEnd(char, i)
+	}
+
+	return nil
+}
+
+/*
+	Handles a character that follows completed chunk extension: it may be a whitespace,
+	beginning of the next extension or the end of chunk size line
+*/
+func (p *chunkedBodyParser) extensionEnd(char byte, i int) error {
+	if isWhitespace(char) {
+		return nil
+	}
+
+	switch char {
+	case ';':
+		p.state = chunkExtensionName
+	case '\r':
+		p.state = chunkLengthCR
+	case '\n':
+		if p.strictness.has(RejectBareLF) {
+			return ErrBareLF
+		}
+
+		p.chunkSizeLineEnd(i + 1)
+	default:
+		return ErrInvalidChunkExtension
+	}
+
+	err := p.onExtension(p.extensionBuffer[:p.extensionValueBegin], p.extensionBuffer[p.extensionValueBegin:])
+	p.extensionBuffer = p.extensionBuffer[:0]
+
+	return err
+}
+
+func (p *chunkedBodyParser) chunkSizeLineEnd(bodyBegin int) {
+	p.extensionsLength = 0
+	p.chunkSizeDigits = 0
+
+	if p.chunkLength == 0 {
+		p.state = lastChunk
+		return
+	}
+
+	p.bodyLength += p.chunkLength
+	p.chunkBodyBegin = bodyBegin
+	p.state = chunkBody
+}
+
+func (p *chunkedBodyParser) parseError(err error, data []byte, i int) *ParseError {
+	parseErr := newParseError(err, data, i)
+	parseErr.State = p.state.String()
+
+	return parseErr
+}
+
+/*
+	Callback asked to stop. Rest is everything after the byte the callback was called on
+*/
+func (p *chunkedBodyParser) pause(rest []byte) (done bool, extraBytes []byte, err error) {
+	p.chunkBodyBegin = 0
+
+	return false, rest, ErrPause
+}
+
 func (p *chunkedBodyParser) complete() {
 	p.state = transferCompleted
 }
+
+func (p *chunkedBodyParser) pushChunk(chunk []byte) error {
+	if p.discardBody {
+		return nil
+	}
+
+	return p.callback(chunk)
+}
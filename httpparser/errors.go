@@ -1,33 +1,109 @@
 package httpparser
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
 type Upgrade struct {
 	protos string
+
+	// Protocols listed in the Upgrade header, in order of preference. Empty
+	// in case of CONNECT request
+	Protocols []string
+	// Bytes that were fed along with the request, but don't belong to it. They
+	// must be passed to the handler of the protocol connection is upgraded to
+	Rest []byte
 }
 
 func (u Upgrade) Error() string {
+	if len(u.protos) == 0 {
+		return "tunnel"
+	}
+
 	return u.protos
 }
 
 func NewUpgrade(protos string) error {
-	return Upgrade{protos: protos}
+	return newUpgrade(protos)
+}
+
+func newUpgrade(protos string) Upgrade {
+	upgrade := Upgrade{protos: protos}
+
+	for _, proto := range strings.Split(protos, ",") {
+		if proto = strings.TrimSpace(proto); len(proto) > 0 {
+			upgrade.Protocols = append(upgrade.Protocols, proto)
+		}
+	}
+
+	return upgrade
+}
+
+/*
+	Returned by Feed() and Resume() in case callback returned ErrPause. Parser stays
+	alive, and the rest of the data is parsed when Resume() is called
+*/
+type Pause struct {
+	// Amount of bytes of the data fed that were parsed before the pause
+	Consumed int
+}
+
+func (p Pause) Error() string {
+	return "paused"
 }
 
 var (
-	ErrInvalidMethod        = errors.New("ErrInvalidMethod: invalid method")
-	ErrInvalidPath          = errors.New("ErrInvalidPath: path is empty or contains disallowed characters")
-	ErrProtocolNotSupported = errors.New("ErrProtocolNotSupported: protocol is not supported")
-	ErrInvalidHeader        = errors.New("ErrInvalidHeader: invalid header line")
-	ErrBufferOverflow       = errors.New("ErrBufferOverflow: buffer overflow")
-	ErrInvalidContentLength = errors.New("ErrInvalidContentLength: invalid value for content-length header")
-	ErrRequestSyntaxError   = errors.New("ErrRequestSyntaxError: request syntax error")
-	ErrBodyTooBig           = errors.New("ErrBodyTooBig: received too much body before connection closed")
+	ErrInvalidMethod          = errors.New("ErrInvalidMethod: invalid method")
+	ErrUnknownMethod          = fmt.Errorf("ErrUnknownMethod: method is not implemented: %w", ErrInvalidMethod)
+	ErrInvalidPath            = errors.New("ErrInvalidPath: path is empty or contains disallowed characters")
+	ErrInvalidAuthority       = errors.New("ErrInvalidAuthority: target of CONNECT request must be host:port")
+	ErrUnsafePath             = errors.New("ErrUnsafePath: path contains encoded NUL or goes above the root")
+	ErrProtocolNotSupported   = errors.New("ErrProtocolNotSupported: protocol is not supported")
+	ErrInvalidHeader          = errors.New("ErrInvalidHeader: invalid header line")
+	ErrWhitespaceBeforeColon  = fmt.Errorf("ErrWhitespaceBeforeColon: whitespace between header key and colon: %w", ErrInvalidHeader)
+	ErrObsFold                = fmt.Errorf("ErrObsFold: header line begins with a whitespace: %w", ErrInvalidHeader)
+	ErrBufferOverflow         = errors.New("ErrBufferOverflow: buffer overflow")
+	ErrPathOverflow           = fmt.Errorf("ErrPathOverflow: path is too long: %w", ErrBufferOverflow)
+	ErrHeaderOverflow         = fmt.Errorf("ErrHeaderOverflow: header line is too long: %w", ErrBufferOverflow)
+	ErrTooManyHeaders         = fmt.Errorf("ErrTooManyHeaders: too many headers: %w", ErrBufferOverflow)
+	ErrHeaderSectionOverflow  = fmt.Errorf("ErrHeaderSectionOverflow: header section is too long: %w", ErrBufferOverflow)
+	ErrProtocolOverflow       = fmt.Errorf("ErrProtocolOverflow: protocol is too long: %w", ErrBufferOverflow)
+	ErrQueryOverflow          = fmt.Errorf("ErrQueryOverflow: too many query parameters or parameter is too long: %w", ErrBufferOverflow)
+	ErrReasonOverflow         = fmt.Errorf("ErrReasonOverflow: reason phrase is too long: %w", ErrBufferOverflow)
+	ErrInvalidQuery           = errors.New("ErrInvalidQuery: query contains invalid percent-encoding")
+	ErrInvalidContentLength   = errors.New("ErrInvalidContentLength: invalid value for content-length header")
+	ErrEmptyContentLength     = fmt.Errorf("ErrEmptyContentLength: content-length is empty: %w", ErrInvalidContentLength)
+	ErrSignedContentLength    = fmt.Errorf("ErrSignedContentLength: content-length must not be signed: %w", ErrInvalidContentLength)
+	ErrContentLengthOverflow  = fmt.Errorf("ErrContentLengthOverflow: content-length is too big: %w", ErrInvalidContentLength)
+	ErrDuplicateContentLength = errors.New("ErrDuplicateContentLength: content-length header is duplicated or has different values")
+	ErrConflictingFraming     = errors.New("ErrConflictingFraming: both content-length and transfer-encoding are presented")
+
+	ErrUnsupportedTransferEncoding = errors.New("ErrUnsupportedTransferEncoding: unknown transfer coding")
+	ErrInvalidTransferEncoding     = errors.New("ErrInvalidTransferEncoding: chunked must be the final transfer coding")
+	ErrRequestSyntaxError          = errors.New("ErrRequestSyntaxError: request syntax error")
+	ErrBareLF                      = fmt.Errorf("ErrBareLF: line is terminated by LF without CR: %w", ErrRequestSyntaxError)
+	ErrBareCR                      = fmt.Errorf("ErrBareCR: CR is not followed by LF: %w", ErrRequestSyntaxError)
+	ErrMultipleSpaces              = fmt.Errorf("ErrMultipleSpaces: start line elements are separated by multiple spaces: %w", ErrRequestSyntaxError)
+	ErrLowercaseProtocol           = fmt.Errorf("ErrLowercaseProtocol: protocol must be upper-cased: %w", ErrRequestSyntaxError)
+	ErrBodyTooBig                  = errors.New("ErrBodyTooBig: body is longer than allowed")
+	ErrInvalidStatusCode           = errors.New("ErrInvalidStatusCode: status code must be a 3-digit number")
+	ErrInvalidReason               = errors.New("ErrInvalidReason: reason phrase contains disallowed characters")
 
 	ErrTooBigChunkSize      = errors.New("ErrTooBigChunkSize: chunk size is too big")
 	ErrInvalidChunkSize     = errors.New("ErrInvalidChunkSize: chunk size is invalid hexdecimal value")
 	ErrInvalidChunkSplitter = errors.New("ErrInvalidChunkSplitter: invalid splitter")
 
+	ErrInvalidChunkExtension = errors.New("ErrInvalidChunkExtension: chunk extension syntax error")
+	ErrTooBigChunkExtensions = errors.New("ErrTooBigChunkExtensions: chunk extensions are too long")
+
 	ErrConnectionClosed = errors.New("ErrConnectionClosed: connection is closed, body has been received")
 	ErrParserIsDead     = errors.New("ErrParserIsDead: once error occurred, parser cannot be used anymore")
+
+	// ErrPause may be returned from callbacks of requests parser to stop parsing without
+	// killing the parser. Responses parser doesn't support pauses, for it this is just
+	// an error as any other
+	ErrPause      = errors.New("ErrPause: parsing is paused by callback")
+	ErrNotResumed = errors.New("ErrNotResumed: parser is paused, Resume() must be called before feeding it again")
 )
@@ -0,0 +1,129 @@
+package httpparser
+
+/*
+	Identifier of a well-known header. Headers that aren't in the table are HeaderUnknown
+*/
+type HeaderID uint8
+
+const (
+	HeaderUnknown HeaderID = iota
+	HeaderAccept
+	HeaderAcceptEncoding
+	HeaderAcceptLanguage
+	HeaderAuthorization
+	HeaderCacheControl
+	HeaderConnection
+	HeaderContentEncoding
+	HeaderContentLength
+	HeaderContentType
+	HeaderCookie
+	HeaderDate
+	HeaderExpect
+	HeaderForwarded
+	HeaderHost
+	HeaderIfModifiedSince
+	HeaderIfNoneMatch
+	HeaderKeepAlive
+	HeaderLocation
+	HeaderOrigin
+	HeaderRange
+	HeaderReferer
+	HeaderServer
+	HeaderSetCookie
+	HeaderTE
+	HeaderTrailer
+	HeaderTransferEncoding
+	HeaderUpgrade
+	HeaderUserAgent
+	HeaderXForwardedFor
+	HeaderXRequestID
+)
+
+// indexed by HeaderID
+var headerNames = [...]string{
+	HeaderUnknown:          "",
+	HeaderAccept:           "Accept",
+	HeaderAcceptEncoding:   "Accept-Encoding",
+	HeaderAcceptLanguage:   "Accept-Language",
+	HeaderAuthorization:    "Authorization",
+	HeaderCacheControl:     "Cache-Control",
+	HeaderConnection:       "Connection",
+	HeaderContentEncoding:  "Content-Encoding",
+	HeaderContentLength:    "Content-Length",
+	HeaderContentType:      "Content-Type",
+	HeaderCookie:           "Cookie",
+	HeaderDate:             "Date",
+	HeaderExpect:           "Expect",
+	HeaderForwarded:        "Forwarded",
+	HeaderHost:             "Host",
+	HeaderIfModifiedSince:  "If-Modified-Since",
+	HeaderIfNoneMatch:      "If-None-Match",
+	HeaderKeepAlive:        "Keep-Alive",
+	HeaderLocation:         "Location",
+	HeaderOrigin:           "Origin",
+	HeaderRange:            "Range",
+	HeaderReferer:          "Referer",
+	HeaderServer:           "Server",
+	HeaderSetCookie:        "Set-Cookie",
+	HeaderTE:               "TE",
+	HeaderTrailer:          "Trailer",
+	HeaderTransferEncoding: "Transfer-Encoding",
+	HeaderUpgrade:          "Upgrade",
+	HeaderUserAgent:        "User-Agent",
+	HeaderXForwardedFor:    "X-Forwarded-For",
+	HeaderXRequestID:       "X-Request-ID",
+}
+
+const maxKnownHeaderLength = len("Transfer-Encoding")
+
+/*
+	Lower-cased names of known headers grouped by their length, so only a few of
+	them are compared with the key
+*/
+var knownHeaders = func() (table [maxKnownHeaderLength + 1][]knownHeader) {
+	for id, name := range headerNames {
+		if len(name) == 0 {
+			continue
+		}
+
+		lowered := make([]byte, len(name))
+
+		for i := 0; i < len(name); i++ {
+			lowered[i] = name[i] | 0x20
+		}
+
+		table[len(name)] = append(table[len(name)], knownHeader{name: lowered, id: HeaderID(id)})
+	}
+
+	return table
+}()
+
+type knownHeader struct {
+	name []byte
+	id   HeaderID
+}
+
+/*
+	Returns canonical name of the header, e.g. Content-Length
+*/
+func (h HeaderID) String() string {
+	if int(h) >= len(headerNames) {
+		return ""
+	}
+
+	return headerNames[h]
+}
+
+func lookupHeader(key []byte) HeaderID {
+	if len(key) > maxKnownHeaderLength {
+		return HeaderUnknown
+	}
+
+	for _, known := range knownHeaders[len(key)] {
+		if EqualFold(known.name, key) {
+			return known.id
+		}
+	}
+
+	return HeaderUnknown
+}
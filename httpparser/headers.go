@@ -0,0 +1,257 @@
+package httpparser
+
+import (
+	"github.com/scott-ainsworth/go-ascii"
+)
+
+type OnHeaderCallback func(key, value []byte) error
+
+/*
+	State machine for the header section. It is shared between requests, responses and
+	chunked body trailers, so all of them are parsed (and limited) in exactly the same way
+*/
+type headersParser struct {
+	callback   OnHeaderCallback
+	state      headersState
+	buffer     []byte
+	valueBegin int
+	// both are counted since the beginning of the section
+	headersCount int
+	sectionSize  int
+
+	maxHeaderLineLength  int
+	maxHeadersCount      int
+	maxHeaderSectionSize int
+	strictness           Strictness
+}
+
+func newHeadersParser(callback OnHeaderCallback, buffer []byte, settings Settings) *headersParser {
+	return &headersParser{
+		callback:             callback,
+		state:                headersBegin,
+		buffer:               buffer,
+		maxHeaderLineLength:  settings.MaxHeaderLineLength,
+		maxHeadersCount:      settings.MaxHeadersCount,
+		maxHeaderSectionSize: settings.MaxHeaderSectionSize,
+		strictness:           settings.Strictness,
+	}
+}
+
+func (p *headersParser) Clear() {
+	p.state = headersBegin
+	p.buffer = p.buffer[:0]
+	p.headersCount = 0
+	p.sectionSize = 0
+}
+
+/*
+	Feeds the parser until an empty line that terminates headers section is met. In
+	this case done flag is set and extra-bytes are returned, as they are not ours.
+	In case callback returns ErrPause, it is returned along with unparsed bytes
+*/
+func (p *headersParser) Feed(data []byte) (done bool, extra []byte, err error) {
+	for i, char := range data {
+		if p.sectionSize++; p.sectionSize > p.maxHeaderSectionSize {
+			return true, nil, newParseError(ErrHeaderSectionOverflow, data, i)
+		}
+
+		switch p.state {
+		case headerKey:
+			if char == ':' {
+				if p.strictness.has(RejectWhitespaceBeforeColon) && isWhitespace(p.buffer[len(p.buffer)-1]) {
+					return true, nil, newParseError(ErrWhitespaceBeforeColon, data, i)
+				}
+
+				p.state = headerColon
+				p.valueBegin = len(p.buffer)
+				break
+			} else if !ascii.IsPrint(char) {
+				return true, nil, newParseError(ErrInvalidHeader, data, i)
+			}
+
+			p.buffer = append(p.buffer, char)
+
+			if len(p.buffer) >= p.maxHeaderLineLength {
+				return true, nil, newParseError(ErrHeaderOverflow, data, i)
+			}
+		case headerColon:
+			switch {
+			case char == '\r':
+				// empty value is still a valid one
+				p.state = headerValueCR
+			case char == '\n':
+				if p.strictness.has(RejectBareLF) {
+					return true, nil, newParseError(ErrBareLF, data, i)
+				}
+
+				p.state = headerValueLF
+			case isWhitespace(char):
+				// leading whitespaces aren't a part of the value
+			case !ascii.IsPrint(char):
+				return true, nil, newParseError(ErrInvalidHeader, data, i)
+			default:
+				p.buffer = append(p.buffer, char)
+				p.state = headerValue
+			}
+		case headerValueCR:
+			if char == '\n' {
+				p.state = headerValueLF
+				break
+			}
+
+			if !p.strictness.has(AllowBareCR) {
+				return true, nil, newParseError(ErrBareCR, data, i)
+			}
+
+			// bare CR is replaced with a space, and the character belongs to the value
+			p.buffer = append(p.buffer, ' ')
+			p.state = headerValue
+
+			fallthrough
+		case headerValue:
+			switch char {
+			case '\r':
+				p.state = headerValueCR
+			case '\n':
+				if p.strictness.has(RejectBareLF) {
+					return true, nil, newParseError(ErrBareLF, data, i)
+				}
+
+				p.state = headerValueLF
+			default:
+				if !ascii.IsPrint(char) && char != '\t' {
+					return true, nil, newParseError(ErrInvalidHeader, data, i)
+				}
+
+				p.buffer = append(p.buffer, char)
+
+				if len(p.buffer) > p.maxHeaderLineLength {
+					return true, nil, newParseError(ErrHeaderOverflow, data, i)
+				}
+			}
+		case headerValueLF:
+			if isWhitespace(char) {
+				if !p.strictness.has(UnfoldObsFold) {
+					return true, nil, newParseError(ErrObsFold, data, i)
+				}
+
+				p.unfold()
+
+				if len(p.buffer) > p.maxHeaderLineLength {
+					return true, nil, newParseError(ErrHeaderOverflow, data, i)
+				}
+
+				break
+			}
+
+			value := p.buffer[p.valueBegin:]
+
+			if p.strictness.has(TrimHeaderValues) {
+				value = trimWhitespace(value)
+			}
+
+			// header line is completed only when we see the first character of the next one
+			if err = p.callback(p.buffer[:p.valueBegin], value); err == ErrPause {
+				// header is completed, so we continue from the beginning of the next one.
+				// Current byte is going to be counted again then
+				p.state = headersBegin
+				p.sectionSize--
+
+				return false, data[i:], err
+			} else if err != nil {
+				if parseErr, ok := err.(*ParseError); ok {
+					// header line turned out to be invalid, but we know it only now
+					parseErr.Offset, parseErr.Byte = int64(i), char
+				}
+
+				return true, nil, err
+			}
+
+			fallthrough
+		case headersBegin:
+			switch char {
+			case '\r':
+				p.state = headerValueDoubleCR
+			case '\n':
+				if p.strictness.has(RejectBareLF) {
+					return true, nil, newParseError(ErrBareLF, data, i)
+				}
+
+				p.Clear()
+
+				return true, data[i+1:], nil
+			default:
+				if isWhitespace(char) {
+					// there is no header to continue
+					return true, nil, newParseError(ErrObsFold, data, i)
+				}
+
+				if !ascii.IsPrint(char) || char == ':' {
+					return true, nil, newParseError(ErrInvalidHeader, data, i)
+				}
+
+				// the header is rejected before its callback is called
+				if p.headersCount++; p.headersCount > p.maxHeadersCount {
+					return true, nil, newParseError(ErrTooManyHeaders, data, i)
+				}
+
+				p.buffer = append(p.buffer[:0], char)
+				p.state = headerKey
+			}
+		case headerObsFold:
+			switch {
+			case isWhitespace(char):
+			case char == '\r':
+				// continuation line is empty, so there is nothing to separate
+				p.trimValueEnd()
+				p.state = headerValueCR
+			case char == '\n':
+				if p.strictness.has(RejectBareLF) {
+					return true, nil, newParseError(ErrBareLF, data, i)
+				}
+
+				p.trimValueEnd()
+				p.state = headerValueLF
+			case !ascii.IsPrint(char):
+				return true, nil, newParseError(ErrInvalidHeader, data, i)
+			default:
+				p.buffer = append(p.buffer, char)
+				p.state = headerValue
+
+				if len(p.buffer) > p.maxHeaderLineLength {
+					return true, nil, newParseError(ErrHeaderOverflow, data, i)
+				}
+			}
+		case headerValueDoubleCR:
+			if char != '\n' {
+				return true, nil, newParseError(ErrBareCR, data, i)
+			}
+
+			p.Clear()
+
+			return true, data[i+1:], nil
+		}
+	}
+
+	return false, nil, nil
+}
+
+/*
+	Replaces the fold (whitespaces around the line break) with a single space. Leading
+	whitespaces of the continuation line are skipped in headerObsFold state
+*/
+func (p *headersParser) unfold() {
+	p.trimValueEnd()
+
+	if len(p.buffer) > p.valueBegin {
+		p.buffer = append(p.buffer, ' ')
+	}
+
+	p.state = headerObsFold
+}
+
+func (p *headersParser) trimValueEnd() {
+	for len(p.buffer) > p.valueBegin && isWhitespace(p.buffer[len(p.buffer)-1]) {
+		p.buffer = p.buffer[:len(p.buffer)-1]
+	}
+}
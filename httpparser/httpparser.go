@@ -1,15 +1,9 @@
 package httpparser
 
 import (
-	"github.com/scott-ainsworth/go-ascii"
-)
+	"bytes"
 
-var (
-	contentLength    = []byte("content-length")
-	transferEncoding = []byte("transfer-encoding")
-	connection       = []byte("connection")
-	chunked          = []byte("chunked")
-	closeConnection  = []byte("close")
+	"github.com/scott-ainsworth/go-ascii"
 )
 
 type Protocol interface {
@@ -24,150 +18,390 @@ type Protocol interface {
 	OnMessageComplete() error
 }
 
+/*
+	Optional callback. In case protocol implements it, every trailer field that
+	follows the last chunk of chunked body is passed here. Otherwise, trailers
+	are just skipped
+*/
+type TrailersProtocol interface {
+	OnTrailer([]byte, []byte) error
+}
+
+/*
+	Optional callback. In case protocol implements it, every extension of every chunk
+	of chunked body is passed here. Extension without a value has an empty one
+*/
+type ChunkExtensionsProtocol interface {
+	OnChunkExtension([]byte, []byte) error
+}
+
+/*
+	Optional callback. In case protocol implements it, every transfer coding that was
+	applied to the body before chunked one is passed here, in order of application.
+	It is called right before OnHeadersComplete()
+*/
+type TransferCodingsProtocol interface {
+	OnTransferCoding([]byte) error
+}
+
+/*
+	Optional callback. In case protocol implements it, it is called right after
+	OnHeadersComplete() for requests with "Expect: 100-continue" header and a body.
+	This is the place to send "100 Continue" response. If proceed is false, body
+	of the request will be skipped, and parser will be ready for the next one (unless
+	CloseOnRejectedExpectation setting is set)
+*/
+type ExpectContinueProtocol interface {
+	OnExpectContinue() (proceed bool, err error)
+}
+
+/*
+	Optional callback. In case protocol implements it, host and port from the target
+	of CONNECT request are passed here, right after OnPath()
+*/
+type ConnectProtocol interface {
+	OnAuthority(host, port []byte) error
+}
+
+/*
+	Optional callback. In case protocol implements it, parser doesn't return Upgrade
+	after CONNECT request, but switches into a tunnel mode instead: every byte that
+	follows the request is passed here until connection is closed
+*/
+type TunnelProtocol interface {
+	OnTunnelData([]byte) error
+}
+
+/*
+	Optional callbacks. In case protocol implements them, request-target of origin-form
+	or absolute-form is split into path, query and fragment, right after OnPath(). Query
+	and fragment are passed without leading '?' and '#', and only in case they are presented
+*/
+type RequestTargetProtocol interface {
+	OnURLPath([]byte) error
+	OnQueryString([]byte) error
+	OnFragment([]byte) error
+}
+
+/*
+	Optional callback. In case protocol implements it, scheme, host and port of
+	absolute-form request-target are passed here, right after OnPath(). Port is empty
+	in case it isn't specified
+*/
+type AbsoluteTargetProtocol interface {
+	OnAbsoluteTarget(scheme, host, port []byte) error
+}
+
+/*
+	Optional callback. In case protocol implements it, every parameter of the query is
+	decoded ('+' becomes a space, percent-encoded bytes are decoded) and passed here,
+	right after OnQueryString(). Key and value are valid only until the callback returns
+*/
+type QueryParamsProtocol interface {
+	OnQueryParam(key, value []byte) error
+}
+
+/*
+	Optional callback. In case protocol implements it, every well-known header is passed
+	here right after OnHeader(), along with its ID, so there is no need to compare its
+	key again. Implemented by response protocol, it works for responses parser too
+*/
+type KnownHeadersProtocol interface {
+	OnKnownHeader(id HeaderID, value []byte) error
+}
+
 type HTTPRequestsParser interface {
 	Feed([]byte) error
+	Resume() error
+	ShouldKeepAlive() bool
+	RequestTargetForm() RequestTargetForm
 	Clear()
 }
 
-type httpRequestParser struct {
-	protocol Protocol
-	settings Settings
-
-	state            parsingState
-	headerValueBegin uint
-	headersBuffer    []byte
-	startLineBuff    []byte
-	startLineOffset  uint
+var continueExpectation = []byte("100-continue")
 
-	bodyBytesLeft int
-
-	closeConnection bool
-	isChunked       bool
-	chunksParser    *chunkedBodyParser
+type httpRequestParser struct {
+	protocol         Protocol
+	onExpectContinue func() (bool, error)
+	onAuthority      func(host, port []byte) error
+	onAbsoluteTarget func(scheme, host, port []byte) error
+	onTarget         RequestTargetProtocol
+	onQueryParam     func(key, value []byte) error
+	onKnownHeader    func(id HeaderID, value []byte) error
+	onTunnelData     func([]byte) error
+	settings         Settings
+
+	state           parsingState
+	headersParser   *headersParser
+	startLineBuff   []byte
+	startLineOffset uint
+
+	persistentByDefault bool
+	persistent          bool
+
+	target         requestTarget
+	query          queryParser
+	isConnect      bool
+	upgradeBuff    []byte
+	expectContinue bool
+
+	pauseRequested bool
+	paused         bool
+	pausedData     []byte
+
+	// callbacks that are still to be called, in case the chain was paused
+	step             parsingStep
+	codingIndex      int
+	knownHeader      HeaderID
+	knownHeaderValue []byte
+	// offset of the space that terminates request-target, since the beginning
+	// of the connection
+	targetEnd int64
+
+	bodyParser
+	connPosition
 }
 
 /*
 	Returns new initialized instance of parser
 */
 func NewHTTPRequestParser(protocol Protocol, settings Settings) (*httpRequestParser, error) {
-	if err := protocol.OnMessageBegin(); err != nil {
+	err := protocol.OnMessageBegin()
+
+	if err != nil && err != ErrPause {
 		return nil, err
 	}
 
 	settings = PrepareSettings(settings)
 
-	return &httpRequestParser{
+	parser := &httpRequestParser{
 		protocol:      protocol,
 		settings:      settings,
-		headersBuffer: settings.HeadersBuffer,
 		startLineBuff: settings.StartLineBuffer,
-		chunksParser:  NewChunkedBodyParser(protocol.OnBody, settings.MaxChunkLength),
+		target:        requestTarget{normalizedPath: make([]byte, 0, settings.InitialPathBufferLength)},
+		query:         newQueryParser(settings),
+		bodyParser:    newBodyParser(protocol, settings),
 		state:         method,
-	}, nil
+		// in case of pause, the first Feed() stops right at the beginning
+		pauseRequested: err == ErrPause,
+	}
+	parser.headersParser = newHeadersParser(parser.onHeader, settings.HeadersBuffer, settings)
+
+	if expectProtocol, ok := protocol.(ExpectContinueProtocol); ok {
+		parser.onExpectContinue = expectProtocol.OnExpectContinue
+	}
+	if connectProtocol, ok := protocol.(ConnectProtocol); ok {
+		parser.onAuthority = connectProtocol.OnAuthority
+	}
+	if tunnelProtocol, ok := protocol.(TunnelProtocol); ok {
+		parser.onTunnelData = tunnelProtocol.OnTunnelData
+	}
+	if absoluteProtocol, ok := protocol.(AbsoluteTargetProtocol); ok {
+		parser.onAbsoluteTarget = absoluteProtocol.OnAbsoluteTarget
+	}
+	if targetProtocol, ok := protocol.(RequestTargetProtocol); ok {
+		parser.onTarget = targetProtocol
+	}
+	if queryProtocol, ok := protocol.(QueryParamsProtocol); ok {
+		parser.onQueryParam = queryProtocol.OnQueryParam
+	}
+	if knownHeadersProtocol, ok := protocol.(KnownHeadersProtocol); ok {
+		parser.onKnownHeader = knownHeadersProtocol.OnKnownHeader
+	}
+
+	return parser, nil
 }
 
 func (p *httpRequestParser) Clear() {
 	p.state = method
-	p.isChunked = false
-	p.headersBuffer = p.headersBuffer[:0]
+	p.headersParser.Clear()
+	p.bodyParser.Clear()
 	p.startLineBuff = p.startLineBuff[:0]
 	p.startLineOffset = 0
+	p.isConnect = false
+	p.upgradeBuff = p.upgradeBuff[:0]
+	p.expectContinue = false
+	p.step = noStep
+	p.codingIndex = 0
+	p.knownHeaderValue = nil
 }
 
 /*
 	This parser is absolutely stand-alone. It's like a separated sub-system in every
 	server, because everything you need is just to feed it
 */
-func (p *httpRequestParser) Feed(data []byte) (reqErr error) {
+func (p *httpRequestParser) Feed(data []byte) error {
+	if p.paused {
+		if len(p.pausedData) > 0 {
+			return ErrNotResumed
+		}
+
+		// nothing to resume, so it's the same
+		p.paused = false
+	}
+
+	p.feeding(data)
+
+	return p.pauseResult(data, p.proceed(data))
+}
+
+/*
+	Continues parsing of the data, that was left unparsed when callback returned
+	ErrPause. Returns the same as Feed() does, but Pause.Consumed is counted from
+	the beginning of the unparsed data
+*/
+func (p *httpRequestParser) Resume() error {
+	if !p.paused {
+		return nil
+	}
+
+	data := p.pausedData
+	p.paused = false
+	p.pausedData = nil
+
 	if len(data) == 0 {
-		if p.closeConnection {
-			p.die()
+		// only callbacks may be left, empty data must not be fed as it means EOF
+		return p.pauseResult(nil, p.runSteps(nil))
+	}
 
-			if reqErr = p.protocol.OnMessageComplete(); reqErr != nil {
-				return reqErr
-			}
+	p.dataOffset = p.fed - int64(len(data))
 
-			reqErr = p.protocol.OnMessageComplete()
+	return p.pauseResult(data, p.proceed(data))
+}
 
-			switch reqErr.(type) {
-			case Upgrade:
-				return reqErr
-			}
+/*
+	Calls the callbacks that were left after a pause, and only then parses the data
+*/
+func (p *httpRequestParser) proceed(data []byte) error {
+	if err := p.runSteps(data); err != nil {
+		return err
+	}
 
-			// to let server know that we received everything, and it's time to close the connection
-			return ErrConnectionClosed
-		}
+	if p.pauseRequested {
+		return p.pause(data)
+	}
 
-		return nil
+	return p.feed(data)
+}
+
+/*
+	Parsing of data has stopped because of ErrPause, or because the data is over. In
+	both cases, pause that was requested by a callback is reported here
+*/
+func (p *httpRequestParser) pauseResult(data []byte, err error) error {
+	switch {
+	case err == ErrPause:
+	case err == nil && p.pauseRequested:
+		// callback asked to stop on the very last byte
+		p.paused = true
+		p.pausedData = nil
+	default:
+		// any other error (even Upgrade) makes the pause meaningless
+		p.pauseRequested = false
+
+		return err
 	}
 
-	switch p.state {
-	case dead:
-		return ErrParserIsDead
-	case messageBegin:
-		if reqErr = p.protocol.OnMessageBegin(); reqErr != nil {
-			p.die()
+	p.pauseRequested = false
 
-			return reqErr
-		}
+	return Pause{Consumed: len(data) - len(p.pausedData)}
+}
 
-		p.state = method
-	case body:
-		done, extra, err := p.pushBodyPiece(data)
+/*
+	Returns nil instead of ErrPause, remembering that parsing must be stopped as soon
+	as the current step is completed
+*/
+func (p *httpRequestParser) pausable(err error) error {
+	if err == ErrPause {
+		p.pauseRequested = true
 
-		if err != nil {
-			p.die()
+		return nil
+	}
 
-			return err
-		}
+	return err
+}
 
-		if done {
-			p.Clear()
+/*
+	Returns the error of the callback, killing the parser in case it's not nil.
+	ErrPause is handled the same way as by pausable()
+*/
+func (p *httpRequestParser) call(err error) error {
+	if err = p.pausable(err); err != nil {
+		p.die()
+	}
 
-			reqErr = p.protocol.OnMessageComplete()
+	return err
+}
 
-			switch reqErr.(type) {
-			case nil:
-			case Upgrade:
-				p.state = messageBegin
+/*
+	Stops the parsing. Rest is the data that isn't parsed yet, it is saved until
+	Resume() is called
+*/
+func (p *httpRequestParser) pause(rest []byte) error {
+	p.paused = true
+	p.pausedData = rest
 
-				return reqErr
-			default:
-				p.die()
+	return ErrPause
+}
 
-				return reqErr
-			}
+func (p *httpRequestParser) feed(data []byte) (reqErr error) {
+	if len(data) == 0 {
+		if p.state == tunnel {
+			p.die()
 
-			if reqErr = p.protocol.OnMessageBegin(); reqErr != nil {
-				p.die()
+			return ErrConnectionClosed
+		}
+
+		if p.state == bodyConnectionClose {
+			p.die()
 
+			// in case Upgrade is returned, it will be returned to the server as is
+			if reqErr = p.pausable(p.protocol.OnMessageComplete()); reqErr != nil {
 				return reqErr
 			}
 
-			if len(extra) > 0 {
-				return p.Feed(extra)
-			}
+			// to let server know that we received everything, and it's time to close the connection
+			return ErrConnectionClosed
 		}
 
 		return nil
 	}
 
+	switch {
+	case p.state == dead:
+		return ErrParserIsDead
+	case p.pauseRequested:
+		return p.pause(data)
+	case p.state == messageBegin:
+		if reqErr = p.pausable(p.protocol.OnMessageBegin()); reqErr != nil {
+			p.die()
+
+			return reqErr
+		}
+
+		p.state = method
+	}
+
 	for i := 0; i < len(data); i++ {
+		if p.pauseRequested {
+			return p.pause(data[i:])
+		}
+
 		switch p.state {
 		case method:
 			if data[i] == ' ' {
-				if !IsMethodValid(p.startLineBuff) {
-					p.die()
-
-					return ErrInvalidMethod
+				if err := checkMethod(p.startLineBuff, &p.settings); err != nil {
+					return p.fail(err, data, i)
 				}
 
-				if reqErr = p.protocol.OnMethod(p.startLineBuff); reqErr != nil {
+				if reqErr = p.pausable(p.protocol.OnMethod(p.startLineBuff)); reqErr != nil {
 					p.die()
 
 					return reqErr
 				}
 
+				p.isConnect = bytes.Equal(p.startLineBuff, CONNECT)
+				p.target.Clear()
 				p.startLineOffset = uint(len(p.startLineBuff))
 				p.state = path
 				break
@@ -175,414 +409,620 @@ func (p *httpRequestParser) Feed(data []byte) (reqErr error) {
 
 			p.startLineBuff = append(p.startLineBuff, data[i])
 
-			if len(p.startLineBuff) > maxMethodLength {
-				p.die()
-
-				return ErrInvalidMethod
+			if len(p.startLineBuff) > p.settings.MaxMethodLength {
+				return p.fail(ErrInvalidMethod, data, i)
 			}
 		case path:
 			if data[i] == ' ' {
 				if uint(len(p.startLineBuff)) == p.startLineOffset {
-					p.die()
+					if p.settings.Strictness.has(AllowMultipleSpaces) {
+						continue
+					}
 
-					return ErrInvalidPath
+					// with a single space as a separator, request-target is just empty
+					return p.fail(ErrInvalidPath, data, i)
 				}
 
-				if reqErr = p.protocol.OnPath(p.startLineBuff[p.startLineOffset:]); reqErr != nil {
+				target := p.startLineBuff[p.startLineOffset:]
+
+				if reqErr = p.pausable(p.protocol.OnPath(target)); reqErr != nil {
 					p.die()
 
 					return reqErr
 				}
 
+				if err := p.target.parse(target, p.startLineBuff[:p.startLineOffset]); err != nil {
+					return p.fail(err, data, i)
+				}
+
+				if p.settings.NormalizePath {
+					if err := p.target.normalize(target); err != nil {
+						return p.fail(err, data, i)
+					}
+				}
+
+				p.targetEnd = p.dataOffset + int64(i)
 				p.startLineOffset += uint(len(p.startLineBuff[p.startLineOffset:]))
 				p.state = protocol
+
+				if reqErr = p.pushTarget(target); reqErr != nil {
+					return reqErr
+				}
+
 				continue
 			} else if !ascii.IsPrint(data[i]) {
-				p.die()
-
-				return ErrInvalidPath
+				return p.fail(ErrInvalidPath, data, i)
 			}
 
+			p.target.scan(data[i], len(p.startLineBuff[p.startLineOffset:]))
 			p.startLineBuff = append(p.startLineBuff, data[i])
 
 			if len(p.startLineBuff[p.startLineOffset:]) > p.settings.MaxPathLength {
-				p.die()
-
-				return ErrBufferOverflow
+				return p.fail(ErrPathOverflow, data, i)
 			}
 		case protocol:
 			switch data[i] {
 			case '\r':
 				p.state = protocolCR
 			case '\n':
+				if p.settings.Strictness.has(RejectBareLF) {
+					return p.fail(ErrBareLF, data, i)
+				}
+
 				p.state = protocolLF
+			case ' ':
+				if uint(len(p.startLineBuff)) == p.startLineOffset {
+					if p.settings.Strictness.has(AllowMultipleSpaces) {
+						continue
+					}
+
+					return p.fail(ErrMultipleSpaces, data, i)
+				}
+
+				fallthrough
 			default:
 				p.startLineBuff = append(p.startLineBuff, data[i])
 
 				if len(p.startLineBuff[p.startLineOffset:]) > maxProtocolLength {
-					p.die()
-
-					return ErrBufferOverflow
+					return p.fail(ErrProtocolOverflow, data, i)
 				}
 			}
 		case protocolCR:
 			if data[i] != '\n' {
-				p.die()
-
-				return ErrRequestSyntaxError
+				return p.fail(ErrBareCR, data, i)
 			}
 
 			p.state = protocolLF
 		case protocolLF:
 			if !IsProtocolSupported(p.startLineBuff[p.startLineOffset:]) {
-				p.die()
+				return p.fail(ErrProtocolNotSupported, data, i)
+			}
 
-				return ErrProtocolNotSupported
+			if err := checkProtocolCase(p.startLineBuff[p.startLineOffset:], p.settings.Strictness); err != nil {
+				return p.fail(err, data, i)
 			}
 
-			if reqErr = p.protocol.OnProtocol(p.startLineBuff[p.startLineOffset:]); reqErr != nil {
+			if reqErr = p.pausable(p.protocol.OnProtocol(p.startLineBuff[p.startLineOffset:])); reqErr != nil {
 				p.die()
 
 				return reqErr
 			}
-			if reqErr = p.protocol.OnHeadersBegin(); reqErr != nil {
-				p.die()
 
+			p.persistentByDefault = isPersistentByDefault(p.startLineBuff[p.startLineOffset:])
+			p.state = headers
+			p.step = stepHeadersBegin
+
+			if reqErr = p.runSteps(nil); reqErr != nil {
 				return reqErr
 			}
 
-			if data[i] == '\r' {
-				p.state = headerValueDoubleCR
-				break
-			} else if data[i] == '\n' {
-				if reqErr = p.protocol.OnHeadersComplete(); reqErr != nil {
-					p.die()
-
-					return reqErr
-				}
-
-				p.Clear()
-				reqErr = p.protocol.OnMessageComplete()
-
-				switch reqErr.(type) {
-				case nil:
-				case Upgrade:
-					p.state = messageBegin
+			if p.pauseRequested {
+				// current byte belongs to headers, so it isn't parsed yet
+				return p.pause(data[i:])
+			}
 
-					return reqErr
-				default:
-					p.die()
+			fallthrough
+		case headers:
+			done, extra, err := p.headersParser.Feed(data[i:])
 
-					return reqErr
-				}
+			if err == ErrPause {
+				return p.pause(extra)
+			} else if err != nil {
+				return p.failIn(err, i)
+			}
 
-				break
-			} else if !ascii.IsPrint(data[i]) || data[i] == ':' {
-				p.die()
+			if !done {
+				return nil
+			}
 
-				return ErrInvalidHeader
+			if p.hasTransferEncoding && !p.isChunked {
+				// we cannot find out where the body ends in this case. Error points
+				// to the line feed that terminates headers
+				return p.fail(ErrInvalidTransferEncoding, data, len(data)-len(extra)-1)
 			}
 
-			p.headersBuffer = append(p.headersBuffer, data[i])
-			p.state = headerKey
-		case headerKey:
-			if data[i] == ':' {
-				p.state = headerColon
-				p.headerValueBegin = uint(len(p.headersBuffer))
-				break
-			} else if !ascii.IsPrint(data[i]) {
-				p.die()
+			p.step = stepTransferCodings
 
-				return ErrInvalidHeader
+			if reqErr = p.runSteps(extra); reqErr != nil {
+				return reqErr
 			}
 
-			p.headersBuffer = append(p.headersBuffer, data[i])
-
-			if len(p.headersBuffer) >= p.settings.MaxHeaderLineLength {
-				p.die()
+			if len(extra) > 0 {
+				p.skip(data, extra)
 
-				return ErrBufferOverflow
+				return p.feed(extra)
 			}
-		case headerColon:
-			p.state = headerValue
 
-			if !ascii.IsPrint(data[i]) {
-				p.die()
+			return nil
+		case body:
+			done, extra, err := p.pushBodyPiece(data[i:])
 
-				return ErrInvalidHeader
+			if err = p.pausable(err); err != nil {
+				return p.failIn(err, i)
 			}
 
-			if data[i] != ' ' {
-				p.headersBuffer = append(p.headersBuffer, data[i])
+			if !done && p.pauseRequested {
+				return p.pause(extra)
 			}
-		case headerValue:
-			switch data[i] {
-			case '\r':
-				p.state = headerValueCR
-			case '\n':
-				p.state = headerValueLF
-			default:
-				if !ascii.IsPrint(data[i]) {
-					p.die()
 
-					return ErrInvalidHeader
+			if done {
+				if reqErr = p.completeMessage(extra); reqErr != nil {
+					return reqErr
 				}
 
-				p.headersBuffer = append(p.headersBuffer, data[i])
+				if len(extra) > 0 {
+					p.skip(data, extra)
 
-				if len(p.headersBuffer) > p.settings.MaxHeaderLineLength {
-					p.die()
-
-					return ErrBufferOverflow
+					return p.feed(extra)
 				}
 			}
-		case headerValueCR:
-			if data[i] != '\n' {
-				p.die()
 
-				return ErrRequestSyntaxError
-			}
+			return nil
+		case bodyConnectionClose:
+			p.bodyBytesLeft -= len(data[i:])
 
-			p.state = headerValueLF
-		case headerValueLF:
-			key, value := p.headersBuffer[:p.headerValueBegin], p.headersBuffer[p.headerValueBegin:]
+			if p.bodyBytesLeft < 0 {
+				return p.fail(ErrBodyTooBig, data, len(data)+p.bodyBytesLeft)
+			}
 
-			if reqErr = p.protocol.OnHeader(key, value); reqErr != nil {
+			if reqErr = p.pausable(p.protocol.OnBody(data[i:])); reqErr != nil {
 				p.die()
 
 				return reqErr
 			}
 
-			switch len(key) {
-			case len(contentLength):
-				good := true
+			return nil
+		case tunnel:
+			if reqErr = p.pausable(p.onTunnelData(data[i:])); reqErr != nil {
+				p.die()
 
-				for j, character := range contentLength {
-					if character != (key[j] | 0x20) {
-						good = false
-						break
-					}
-				}
+				return reqErr
+			}
 
-				if good {
-					var err error
+			return nil
+		}
+	}
 
-					if p.bodyBytesLeft, err = parseUint(value); err != nil {
-						p.die()
+	return nil
+}
 
-						return ErrInvalidContentLength
-					}
-				}
-			case len(transferEncoding):
-				good := true
+func (p *httpRequestParser) onHeader(key, value []byte) error {
+	// in case of ErrPause, header is inspected anyway, and only then parsing stops
+	paused := p.protocol.OnHeader(key, value)
 
-				for j, character := range transferEncoding {
-					if character != (key[j] | 0x20) {
-						good = false
-						break
-					}
-				}
+	if paused != nil && paused != ErrPause {
+		return paused
+	}
 
-				if good {
-					// TODO: maybe, there are some more transfer encodings I must support?
-					p.isChunked = EqualFold(chunked, value)
-				}
-			case len(connection):
-				good := true
+	id := lookupHeader(key)
 
-				for j, character := range connection {
-					if character != (key[j] | 0x20) {
-						good = false
-						break
-					}
-				}
+	if id != HeaderUnknown && p.onKnownHeader != nil {
+		if paused == nil {
+			paused = p.onKnownHeader(id, value)
 
-				if good {
-					p.closeConnection = EqualFold(closeConnection, value)
-				}
+			if paused != nil && paused != ErrPause {
+				return paused
 			}
+		} else {
+			// the value stays untouched until the next header is parsed
+			p.knownHeader, p.knownHeaderValue = id, value
+			p.step = stepKnownHeader
+		}
+	}
 
-			switch data[i] {
-			case '\r':
-				p.state = headerValueDoubleCR
-			case '\n':
-				if reqErr = p.protocol.OnHeadersComplete(); reqErr != nil {
-					p.die()
+	switch id {
+	case HeaderUpgrade:
+		if len(p.upgradeBuff) > 0 {
+			p.upgradeBuff = append(p.upgradeBuff, ',')
+		}
 
-					return reqErr
-				}
+		p.upgradeBuff = append(p.upgradeBuff, value...)
+	case HeaderExpect:
+		p.expectContinue = EqualFold(continueExpectation, trimWhitespace(value))
+	}
 
-				if p.closeConnection {
-					p.state = bodyConnectionClose
-					// anyway in case of empty byte data it will stop parsing, so it's safe
-					// but also keeps amount of body bytes limited
-					p.bodyBytesLeft = p.settings.MaxBodyLength
-					break
-				} else if p.bodyBytesLeft == 0 && !p.isChunked {
-					p.Clear()
-					reqErr = p.protocol.OnMessageComplete()
-
-					switch reqErr.(type) {
-					case nil:
-					case Upgrade:
-						p.state = messageBegin
-
-						return reqErr
-					default:
-						p.die()
-
-						return reqErr
-					}
+	if err := p.inspectHeader(id, value); err != nil {
+		// position is filled by headers parser
+		return &ParseError{Err: err}
+	}
 
-					if reqErr = p.protocol.OnMessageBegin(); reqErr != nil {
-						p.die()
+	return paused
+}
 
-						return reqErr
-					}
+/*
+	Calls the callbacks that are left in the chain, one by one. Stops as soon as one
+	of them asks for a pause, the rest are called by Resume() then
+*/
+func (p *httpRequestParser) runSteps(rest []byte) error {
+	for p.step != noStep && !p.pauseRequested {
+		step := p.step
+		p.step = noStep
 
-					break
-				}
+		if err := p.runStep(step, rest); err != nil {
+			return err
+		}
+	}
 
-				p.state = body
-			default:
-				p.headersBuffer = append(p.headersBuffer[:0], data[i])
-				p.state = headerKey
-			}
-		case headerValueDoubleCR:
-			if data[i] != '\n' {
-				p.die()
+	return nil
+}
 
-				return ErrRequestSyntaxError
-			} else if p.closeConnection {
-				p.state = bodyConnectionClose
-				p.bodyBytesLeft = p.settings.MaxBodyLength
-				break
-			} else if p.bodyBytesLeft == 0 && !p.isChunked {
-				p.Clear()
-				reqErr = p.protocol.OnMessageComplete()
+/*
+	Calls a single callback of the chain, and sets the next step. Rest is everything
+	that was fed after the message, in case headers are already completed
+*/
+func (p *httpRequestParser) runStep(step parsingStep, rest []byte) error {
+	switch step {
+	case stepHeadersBegin:
+		return p.call(p.protocol.OnHeadersBegin())
+	case stepAuthority:
+		return p.call(p.onAuthority(p.target.host, p.target.port))
+	case stepAbsoluteTarget:
+		if p.onTarget != nil || p.onQueryParam != nil {
+			p.step = stepURLPath
+		}
 
-				switch reqErr.(type) {
-				case nil:
-				case Upgrade:
-					p.state = messageBegin
+		return p.call(p.onAbsoluteTarget(p.target.scheme, p.target.host, p.target.port))
+	case stepURLPath:
+		p.step = stepQueryString
 
-					return reqErr
-				default:
-					p.die()
+		if p.onTarget != nil {
+			return p.call(p.onTarget.OnURLPath(p.target.path))
+		}
+	case stepQueryString:
+		p.step = stepFragment
 
-					return reqErr
-				}
+		if p.target.query == nil {
+			return nil
+		}
 
-				if reqErr = p.protocol.OnMessageBegin(); reqErr != nil {
-					p.die()
+		if p.onQueryParam != nil {
+			p.query.reset(p.target.query)
+			p.step = stepQueryParams
+		}
 
-					return reqErr
-				}
+		if p.onTarget != nil {
+			return p.call(p.onTarget.OnQueryString(p.target.query))
+		}
+	case stepQueryParams:
+		key, value, ok, err := p.query.next()
 
-				break
-			}
+		switch {
+		case err != nil:
+			return p.failTarget(err)
+		case !ok:
+			p.step = stepFragment
 
-			p.state = body
-		case body:
-			done, extra, err := p.pushBodyPiece(data[i:])
+			return nil
+		}
 
-			if err != nil {
-				p.die()
+		p.step = stepQueryParams
 
-				return err
-			}
+		return p.call(p.onQueryParam(key, value))
+	case stepFragment:
+		if p.onTarget != nil && p.target.fragment != nil {
+			return p.call(p.onTarget.OnFragment(p.target.fragment))
+		}
+	case stepKnownHeader:
+		value := p.knownHeaderValue
+		p.knownHeaderValue = nil
+
+		return p.call(p.onKnownHeader(p.knownHeader, value))
+	case stepTransferCodings:
+		var err error
+		p.step = stepHeadersComplete
+
+		if p.codingIndex, err = p.pushTransferCodings(p.codingIndex); err == ErrPause {
+			// the rest of codings, if any
+			p.step = stepTransferCodings
+		}
 
-			if done {
-				p.Clear()
+		return p.call(err)
+	case stepHeadersComplete:
+		p.persistent = p.keepAlive(p.persistentByDefault)
+		p.step = stepBodyBegin
+
+		return p.call(p.protocol.OnHeadersComplete())
+	case stepBodyBegin:
+		return p.beginBody(rest)
+	case stepMessageComplete:
+		return p.finishMessage(rest)
+	case stepMessageBegin:
+		return p.call(p.protocol.OnMessageBegin())
+	}
 
-				reqErr = p.protocol.OnMessageComplete()
+	return nil
+}
 
-				switch reqErr.(type) {
-				case nil:
-				case Upgrade:
-					// this is the only place where this state is used only
-					// because only here we really need it. In case connection
-					// may be upgraded, we may not need this parser to parse
-					// next message, so calling OnMessageBegin() is necessary
-					p.state = messageBegin
+/*
+	Decides what follows the headers. Message may be completed right here, in this
+	case it's done by the next step
+*/
+func (p *httpRequestParser) beginBody(rest []byte) error {
+	if p.isConnect && p.onTunnelData != nil {
+		return p.openTunnel(rest)
+	}
 
-					return reqErr
-				default:
-					p.die()
+	if p.isConnect || p.isUpgrade() && !p.hasBody() {
+		return p.upgrade(rest)
+	}
 
-					return reqErr
-				}
+	switch {
+	case p.isChunked:
+		// even if Content-Length is also presented, it is ignored
+		p.state = body
+	case p.hasContentLength:
+		if p.bodyBytesLeft == 0 {
+			p.step = stepMessageComplete
 
-				if reqErr = p.protocol.OnMessageBegin(); reqErr != nil {
-					p.die()
+			return nil
+		}
 
-					return reqErr
-				}
+		p.state = body
+	case p.closeConnection:
+		p.state = bodyConnectionClose
+		// anyway in case of empty byte data it will stop parsing, so it's safe
+		// but also keeps amount of body bytes limited
+		p.bodyBytesLeft = p.settings.MaxBodyLength
+	default:
+		p.step = stepMessageComplete
 
-				if reqErr = p.Feed(extra); reqErr != nil {
-					return reqErr
-				}
-			}
+		return nil
+	}
 
-			return nil
-		case bodyConnectionClose:
-			p.bodyBytesLeft -= len(data[i:])
+	if p.state == body && p.expectContinue {
+		return p.checkExpectation()
+	}
 
-			if p.bodyBytesLeft < 0 {
-				p.die()
+	return nil
+}
 
-				return ErrBodyTooBig
-			}
+/*
+	Client waits for our decision before sending the body. In case protocol doesn't
+	care about expectations, body is just received as usual
+*/
+func (p *httpRequestParser) checkExpectation() error {
+	if p.onExpectContinue == nil {
+		return nil
+	}
 
-			if reqErr = p.protocol.OnBody(data[i:]); reqErr != nil {
-				p.die()
+	proceed, err := p.onExpectContinue()
 
-				return reqErr
-			}
+	if err = p.call(err); err != nil {
+		return err
+	}
+
+	if !proceed {
+		if p.settings.CloseOnRejectedExpectation {
+			// client may not send the body at all, so nothing after the request is parsed
+			p.persistent = false
+			p.forceClose = true
+			p.step = stepMessageComplete
 
 			return nil
 		}
+
+		p.skipBody()
 	}
 
 	return nil
 }
 
-func (p *httpRequestParser) die() {
-	p.state = dead
-	// anyway we don't need them anymore
-	p.headersBuffer = nil
-	p.startLineBuff = nil
+/*
+	Returns form of request-target of the request that is currently parsed. Result is
+	available since OnPath(), and until the method of the next request is parsed
+*/
+func (p *httpRequestParser) RequestTargetForm() RequestTargetForm {
+	return p.target.form
 }
 
-func (p *httpRequestParser) pushBodyPiece(data []byte) (done bool, extra []byte, err error) {
-	if p.isChunked {
-		done, extra, err = p.chunksParser.Feed(data)
+/*
+	Passes components of the request-target to the optional callbacks
+*/
+func (p *httpRequestParser) pushTarget(target []byte) error {
+	switch p.target.form {
+	case AuthorityForm:
+		if p.onAuthority != nil {
+			p.step = stepAuthority
+		}
+	case AbsoluteForm, OriginForm:
+		if p.onTarget != nil || p.onQueryParam != nil {
+			p.target.splitComponents(target)
+			p.step = stepURLPath
+		}
 
-		return done, extra, err
+		if p.target.form == AbsoluteForm && p.onAbsoluteTarget != nil {
+			p.step = stepAbsoluteTarget
+		}
 	}
 
-	dataLen := len(data)
+	return p.runSteps(nil)
+}
 
-	if p.bodyBytesLeft > dataLen {
-		if err = p.protocol.OnBody(data); err != nil {
-			return true, nil, err
-		}
+/*
+	Invalid query is found only when its parameters are pushed, so the error points
+	to the space that terminates request-target
+*/
+func (p *httpRequestParser) failTarget(err error) error {
+	err = &ParseError{
+		Err:          err,
+		State:        path.String(),
+		Offset:       p.targetEnd,
+		Byte:         ' ',
+		MessageIndex: p.messageIndex,
+	}
+	p.die()
+
+	return err
+}
 
-		p.bodyBytesLeft -= dataLen
+/*
+	Returns whether connection may be reused for the next request, according to
+	Connection header and protocol version. Result is available since headers of
+	the request are completed, and until headers of the next one are completed
+*/
+func (p *httpRequestParser) ShouldKeepAlive() bool {
+	return p.persistent
+}
 
-		return false, nil, nil
+/*
+	Request asks to switch the connection to another protocol. In case it has a body,
+	the body is still HTTP one, so the upgrade happens only once it's received
+*/
+func (p *httpRequestParser) isUpgrade() bool {
+	return p.upgradeConnection && len(p.upgradeBuff) > 0
+}
+
+func (p *httpRequestParser) hasBody() bool {
+	return p.isChunked || p.hasContentLength && p.bodyBytesLeft > 0
+}
+
+/*
+	Connection is going to be switched to another protocol (or to a tunnel in case of
+	CONNECT), so parsing stops right after the message. Everything after it doesn't
+	belong to HTTP anymore, so it is returned to the server within Upgrade
+*/
+func (p *httpRequestParser) upgrade(rest []byte) error {
+	upgrade := newUpgrade(string(p.upgradeBuff))
+	p.Clear()
+	p.messageIndex++
+
+	switch err := p.pausable(p.protocol.OnMessageComplete()).(type) {
+	case nil:
+	case Upgrade:
+		// protocol knows better which protocol it's going to switch to
+		upgrade = err
+	default:
+		p.die()
+
+		return err
 	}
 
-	if p.bodyBytesLeft <= 0 {
-		// already?? Looks like a bug
-		return true, data, nil
+	upgrade.Rest = rest
+	p.state = messageBegin
+
+	return upgrade
+}
+
+/*
+	After CONNECT request the connection doesn't carry HTTP anymore, so everything
+	that follows it (including rest) is just passed to OnTunnelData(). Protocol still
+	may return Upgrade from OnMessageComplete() to get the bytes back instead
+*/
+func (p *httpRequestParser) openTunnel(rest []byte) error {
+	p.Clear()
+	p.messageIndex++
+	p.persistent = false
+
+	switch err := p.pausable(p.protocol.OnMessageComplete()).(type) {
+	case nil:
+	case Upgrade:
+		err.Rest = rest
+		p.state = messageBegin
+
+		return err
+	default:
+		p.die()
+
+		return err
+	}
+
+	// rest is fed again by the caller, so it gets into the tunnel too
+	p.state = tunnel
+
+	return nil
+}
+
+/*
+	Rest is everything that was fed after the message. It isn't parsed here, but in
+	case connection is upgraded, it is returned to the server within Upgrade
+*/
+func (p *httpRequestParser) completeMessage(rest []byte) error {
+	p.step = stepMessageComplete
+
+	return p.runSteps(rest)
+}
+
+func (p *httpRequestParser) finishMessage(rest []byte) (err error) {
+	if p.isUpgrade() && !p.forceClose {
+		return p.upgrade(rest)
 	}
 
-	if err = p.protocol.OnBody(data[:p.bodyBytesLeft]); err != nil {
-		return true, nil, err
+	forceClose := p.forceClose
+	p.Clear()
+	p.messageIndex++
+	err = p.pausable(p.protocol.OnMessageComplete())
+
+	switch upgrade := err.(type) {
+	case nil:
+		if forceClose {
+			// everything after ambiguous request may be a smuggled one, so we
+			// won't parse it anymore
+			p.die()
+
+			return ErrConnectionClosed
+		}
+	case Upgrade:
+		// In case connection may be upgraded, we may not need this parser to
+		// parse next message, so OnMessageBegin() will be called only when
+		// parser will be fed again
+		p.state = messageBegin
+		upgrade.Rest = rest
+
+		return upgrade
+	default:
+		p.die()
+
+		return err
 	}
 
-	return true, data[p.bodyBytesLeft:], nil
+	p.step = stepMessageBegin
+
+	return nil
+}
+
+/*
+	Kills the parser with the parse error found at data[i]
+*/
+func (p *httpRequestParser) fail(err error, data []byte, i int) error {
+	return p.failIn(newParseError(err, data, i), 0)
+}
+
+/*
+	Kills the parser with the error returned by sub-parser, that was fed with data[offset:]
+*/
+func (p *httpRequestParser) failIn(err error, offset int) error {
+	err = p.locate(err, p.state, offset)
+	p.die()
+
+	return err
+}
+
+func (p *httpRequestParser) die() {
+	p.state = dead
+	p.step = noStep
+	// anyway we don't need them anymore
+	p.headersParser.buffer = nil
+	p.startLineBuff = nil
 }
 
 func IsProtocolSupported(proto []byte) (isSupported bool) {
@@ -1,22 +1,42 @@
 package httpparser
 
-func parseUint(raw []byte) (num int, err error) {
-	/*
-		Tiny implementation of strconv.Atoi, but using directly bytes array,
-		and returning only one error in case of shit - InvalidContentLength
+const (
+	maxInt = int(^uint(0) >> 1)
+	// 9223372036854775807 has 19 digits, and so may any valid Content-Length
+	maxContentLengthDigits = 19
+)
 
-		Parses 10-numeral system integers
-	*/
+/*
+	Parses a single value of Content-Length header. It's a decimal number without a
+	sign, that may be surrounded by whitespaces. Empty, signed, too long or otherwise
+	invalid values are rejected with different errors, every one of them wraps
+	ErrInvalidContentLength
+*/
+func ParseContentLength(value []byte) (length int, err error) {
+	value = trimWhitespace(value)
 
-	for _, char := range raw {
-		char -= '0'
+	switch {
+	case len(value) == 0:
+		return 0, ErrEmptyContentLength
+	case value[0] == '+' || value[0] == '-':
+		return 0, ErrSignedContentLength
+	case len(value) > maxContentLengthDigits:
+		return 0, ErrContentLengthOverflow
+	}
 
-		if char > 9 {
+	for _, char := range value {
+		if char < '0' || char > '9' {
 			return 0, ErrInvalidContentLength
 		}
 
-		num = num*10 + int(char)
+		digit := int(char - '0')
+
+		if length > (maxInt-digit)/10 {
+			return 0, ErrContentLengthOverflow
+		}
+
+		length = length*10 + digit
 	}
 
-	return num, nil
+	return length, nil
 }
@@ -27,3 +27,34 @@ func IsMethodValid(method []byte) bool {
 
 	return false
 }
+
+/*
+	Standard methods are checked first, as they are the most frequent ones. Then
+	methods from settings are checked, and the last chance is any token in case
+	it's allowed by settings
+*/
+/*
+	Method that isn't even a token is malformed, but a token that isn't allowed is
+	just a method we don't implement
+*/
+func checkMethod(method []byte, settings *Settings) error {
+	if IsMethodValid(method) {
+		return nil
+	}
+
+	if !isToken(method) {
+		return ErrInvalidMethod
+	}
+
+	for _, extraMethod := range settings.ExtraMethods {
+		if bytes.Equal(extraMethod, method) {
+			return nil
+		}
+	}
+
+	if !settings.TokenMethods {
+		return ErrUnknownMethod
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package httpparser
+
+/*
+	Unreserved characters (RFC 3986, section 2.3) mean the same whether they are
+	percent-encoded or not, so they are always decoded
+*/
+func isUnreserved(char byte) bool {
+	switch {
+	case char >= 'a' && char <= 'z', char >= 'A' && char <= 'Z', char >= '0' && char <= '9':
+		return true
+	}
+
+	return char == '-' || char == '.' || char == '_' || char == '~'
+}
+
+func unhex(char byte) (value byte, ok bool) {
+	if hexValues[char] == -1 {
+		return 0, false
+	}
+
+	return byte(hexValues[char]), true
+}
+
+/*
+	Writes normalized path into dst and returns it. Unreserved characters are decoded,
+	empty segments (//) and dot segments are removed as RFC 3986, section 5.2.4 says.
+	Encoded NUL and going above the root are rejected with ErrUnsafePath. Trailing
+	slash is kept as is. Path must begin with a slash
+*/
+func normalizePath(dst, path []byte) ([]byte, error) {
+	// dst always ends with a slash while segments are appended
+	dst = append(dst[:0], '/')
+	trailingSlash := true
+
+	for i := 1; i <= len(path); {
+		segmentBegin := len(dst)
+
+		for ; i < len(path) && path[i] != '/'; i++ {
+			char := path[i]
+
+			if char == '%' {
+				if i+2 >= len(path) {
+					return nil, ErrInvalidPath
+				}
+
+				high, highOk := unhex(path[i+1])
+				low, lowOk := unhex(path[i+2])
+
+				if !highOk || !lowOk {
+					return nil, ErrInvalidPath
+				}
+
+				if decoded := high<<4 | low; decoded == 0 {
+					return nil, ErrUnsafePath
+				} else if isUnreserved(decoded) {
+					dst = append(dst, decoded)
+					i += 2
+
+					continue
+				}
+			}
+
+			dst = append(dst, char)
+		}
+
+		// skip the slash
+		i++
+
+		switch segment := dst[segmentBegin:]; {
+		case len(segment) == 0, string(segment) == ".":
+			dst = dst[:segmentBegin]
+			trailingSlash = true
+		case string(segment) == "..":
+			if segmentBegin == 1 {
+				return nil, ErrUnsafePath
+			}
+
+			// the previous segment with its trailing slash
+			dst = dst[:segmentBegin-1]
+
+			for dst[len(dst)-1] != '/' {
+				dst = dst[:len(dst)-1]
+			}
+
+			trailingSlash = true
+		default:
+			dst = append(dst, '/')
+			trailingSlash = false
+		}
+	}
+
+	if !trailingSlash {
+		dst = dst[:len(dst)-1]
+	}
+
+	return dst, nil
+}
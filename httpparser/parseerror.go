@@ -0,0 +1,83 @@
+package httpparser
+
+import "fmt"
+
+/*
+	Returned in case data fed to the parser is invalid. It points to the byte the
+	error was found at, while the sentinel error itself is still reachable via
+	errors.Is(). Errors returned by callbacks are never wrapped
+*/
+type ParseError struct {
+	Err error
+	// Name of the parser state the error was found in. In case it was found by
+	// chunked body parser, its state is appended, e.g. body/chunkLength
+	State string
+	// Offset of the offending byte since the beginning of the connection. For
+	// stand-alone chunked body parser it is relative to the data fed
+	Offset int64
+	Byte   byte
+	// Index of the message in the connection, starting from 0
+	MessageIndex int
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf(
+		"%s (state %s, offset %d, byte %q, message %d)", e.Err, e.State, e.Offset, e.Byte, e.MessageIndex,
+	)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+func newParseError(err error, data []byte, i int) *ParseError {
+	return &ParseError{
+		Err:    err,
+		Offset: int64(i),
+		Byte:   data[i],
+	}
+}
+
+/*
+	Sub-parsers know only the offset within the data they were fed with. Parsers of
+	messages know where this data is in the connection, so they make the offset absolute
+*/
+type connPosition struct {
+	// offset of the first byte of the data currently parsed
+	dataOffset int64
+	fed        int64
+
+	messageIndex int
+}
+
+func (c *connPosition) feeding(data []byte) {
+	c.dataOffset = c.fed
+	c.fed += int64(len(data))
+}
+
+/*
+	Moves to rest, which is a tail of data
+*/
+func (c *connPosition) skip(data, rest []byte) {
+	c.dataOffset += int64(len(data) - len(rest))
+}
+
+/*
+	Makes position of the parse error absolute. The error was found in data[offset:]
+	in the state passed. State of the sub-parser, if any, is kept. Other errors are
+	returned as is
+*/
+func (c *connPosition) locate(err error, state parsingState, offset int) error {
+	if parseErr, ok := err.(*ParseError); ok {
+		if len(parseErr.State) > 0 {
+			parseErr.State = state.String() + "/" + parseErr.State
+		} else {
+			parseErr.State = state.String()
+		}
+
+		parseErr.Offset += c.dataOffset + int64(offset)
+		parseErr.MessageIndex = c.messageIndex
+	}
+
+	return err
+}
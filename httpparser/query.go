@@ -0,0 +1,93 @@
+package httpparser
+
+import "bytes"
+
+/*
+	Splits query into parameters and decodes them. Decoded parameters are written
+	into the same buffer every time, so slices passed to callbacks are valid only
+	until the callback returns
+*/
+type queryParser struct {
+	buff  []byte
+	query []byte
+
+	params         int
+	maxParams      int
+	maxParamLength int
+}
+
+func newQueryParser(settings Settings) queryParser {
+	return queryParser{
+		buff:           make([]byte, 0, settings.MaxQueryParamLength),
+		maxParams:      settings.MaxQueryParams,
+		maxParamLength: settings.MaxQueryParamLength,
+	}
+}
+
+func (q *queryParser) reset(query []byte) {
+	q.query = query
+	q.params = 0
+}
+
+/*
+	Returns the next decoded parameter, or ok=false in case the query is over. Empty
+	parameters (a&&b) are skipped, parameter without '=' has an empty value
+*/
+func (q *queryParser) next() (key, value []byte, ok bool, err error) {
+	var param []byte
+
+	for len(param) == 0 {
+		if len(q.query) == 0 {
+			return nil, nil, false, nil
+		}
+
+		if separator := bytes.IndexByte(q.query, '&'); separator == -1 {
+			param, q.query = q.query, nil
+		} else {
+			param, q.query = q.query[:separator], q.query[separator+1:]
+		}
+	}
+
+	if q.params++; q.params > q.maxParams || len(param) > q.maxParamLength {
+		return nil, nil, false, ErrQueryOverflow
+	}
+
+	q.buff = q.buff[:0]
+	keyLength := -1
+
+	for i := 0; i < len(param); i++ {
+		switch char := param[i]; char {
+		case '=':
+			if keyLength == -1 {
+				keyLength = len(q.buff)
+				continue
+			}
+
+			q.buff = append(q.buff, char)
+		case '+':
+			q.buff = append(q.buff, ' ')
+		case '%':
+			if i+2 >= len(param) {
+				return nil, nil, false, ErrInvalidQuery
+			}
+
+			high, highOk := unhex(param[i+1])
+			low, lowOk := unhex(param[i+2])
+
+			if !highOk || !lowOk {
+				return nil, nil, false, ErrInvalidQuery
+			}
+
+			q.buff = append(q.buff, high<<4|low)
+			i += 2
+		default:
+			q.buff = append(q.buff, char)
+		}
+	}
+
+	if keyLength == -1 {
+		keyLength = len(q.buff)
+	}
+
+	return q.buff[:keyLength], q.buff[keyLength:], true, nil
+}
@@ -0,0 +1,204 @@
+package httpparser
+
+// position of the data within the buffer, as the buffer may be reallocated
+type span struct {
+	begin, end int
+}
+
+func (s span) of(buff []byte) []byte {
+	return buff[s.begin:s.end]
+}
+
+type headerField struct {
+	key, value span
+}
+
+/*
+	Header fields in order they were received. All keys and values are stored in a
+	single buffer that is reused after Reset(), so they must be copied in case they
+	are needed after that
+*/
+type Header struct {
+	buff   []byte
+	fields []headerField
+}
+
+func (h *Header) Add(key, value []byte) {
+	field := headerField{key: span{begin: len(h.buff)}}
+	h.buff = append(h.buff, key...)
+	field.key.end = len(h.buff)
+	field.value.begin = len(h.buff)
+	h.buff = append(h.buff, value...)
+	field.value.end = len(h.buff)
+
+	h.fields = append(h.fields, field)
+}
+
+/*
+	Returns value of the first field with the key, ignoring case. Found is false in
+	case there is no such field
+*/
+func (h *Header) Get(key string) (value []byte, found bool) {
+	for _, field := range h.fields {
+		if equalFoldString(field.key.of(h.buff), key) {
+			return field.value.of(h.buff), true
+		}
+	}
+
+	return nil, false
+}
+
+/*
+	Returns values of all the fields with the key, ignoring case
+*/
+func (h *Header) Values(key string) (values [][]byte) {
+	for _, field := range h.fields {
+		if equalFoldString(field.key.of(h.buff), key) {
+			values = append(values, field.value.of(h.buff))
+		}
+	}
+
+	return values
+}
+
+/*
+	Calls fn for every field in order they were received, until fn returns false
+*/
+func (h *Header) Each(fn func(key, value []byte) bool) {
+	for _, field := range h.fields {
+		if !fn(field.key.of(h.buff), field.value.of(h.buff)) {
+			return
+		}
+	}
+}
+
+func (h *Header) Len() int {
+	return len(h.fields)
+}
+
+func (h *Header) Reset() {
+	h.buff = h.buff[:0]
+	h.fields = h.fields[:0]
+}
+
+/*
+	Parsed request. Start line and body are stored in a single buffer, that, as the
+	buffers of headers and trailers, is reused after Reset(). So Request may be put
+	into sync.Pool and taken from it for the next request without allocations. Whole
+	body is stored, so it's limited only by MaxBodyLength setting
+*/
+type Request struct {
+	Header  Header
+	Trailer Header
+
+	buff     []byte
+	method   span
+	path     span
+	protocol span
+	body     span
+}
+
+func (r *Request) Method() []byte {
+	return r.method.of(r.buff)
+}
+
+func (r *Request) Path() []byte {
+	return r.path.of(r.buff)
+}
+
+func (r *Request) Protocol() []byte {
+	return r.protocol.of(r.buff)
+}
+
+func (r *Request) Body() []byte {
+	return r.body.of(r.buff)
+}
+
+func (r *Request) Reset() {
+	r.Header.Reset()
+	r.Trailer.Reset()
+	r.buff = r.buff[:0]
+	r.method, r.path, r.protocol, r.body = span{}, span{}, span{}, span{}
+}
+
+func (r *Request) push(data []byte) span {
+	begin := len(r.buff)
+	r.buff = append(r.buff, data...)
+
+	return span{begin: begin, end: len(r.buff)}
+}
+
+/*
+	Ready-made protocol, that stores every request into Request. It is reset when the
+	next request begins, so OnRequest is the place to handle the request. Trailers
+	are stored too
+*/
+type RequestProtocol struct {
+	Request   Request
+	OnRequest func(*Request) error
+}
+
+func NewRequestProtocol(onRequest func(*Request) error) *RequestProtocol {
+	return &RequestProtocol{OnRequest: onRequest}
+}
+
+func (p *RequestProtocol) OnMessageBegin() error {
+	p.Request.Reset()
+
+	return nil
+}
+
+func (p *RequestProtocol) OnMethod(method []byte) error {
+	p.Request.method = p.Request.push(method)
+
+	return nil
+}
+
+func (p *RequestProtocol) OnPath(path []byte) error {
+	p.Request.path = p.Request.push(path)
+
+	return nil
+}
+
+func (p *RequestProtocol) OnProtocol(protocol []byte) error {
+	p.Request.protocol = p.Request.push(protocol)
+
+	return nil
+}
+
+func (p *RequestProtocol) OnHeadersBegin() error {
+	return nil
+}
+
+func (p *RequestProtocol) OnHeader(key, value []byte) error {
+	p.Request.Header.Add(key, value)
+
+	return nil
+}
+
+func (p *RequestProtocol) OnHeadersComplete() error {
+	p.Request.body = span{begin: len(p.Request.buff), end: len(p.Request.buff)}
+
+	return nil
+}
+
+func (p *RequestProtocol) OnBody(piece []byte) error {
+	// nothing is pushed into the buffer after the body, so it's contiguous
+	p.Request.body.end = p.Request.push(piece).end
+
+	return nil
+}
+
+func (p *RequestProtocol) OnTrailer(key, value []byte) error {
+	p.Request.Trailer.Add(key, value)
+
+	return nil
+}
+
+func (p *RequestProtocol) OnMessageComplete() error {
+	if p.OnRequest != nil {
+		return p.OnRequest(&p.Request)
+	}
+
+	return nil
+}
@@ -0,0 +1,478 @@
+package httpparser
+
+import (
+	"bytes"
+
+	"github.com/scott-ainsworth/go-ascii"
+)
+
+type ResponseProtocol interface {
+	OnProtocol([]byte) error
+	OnStatusCode(int) error
+	OnReason([]byte) error
+	OnHeader([]byte, []byte) error
+	OnBody([]byte) error
+	OnMessageComplete() error
+}
+
+/*
+	Unlike requests parser, responses one can't be paused: ErrPause returned from any
+	callback kills it, as any other error does
+*/
+type HTTPResponsesParser interface {
+	Feed([]byte) error
+	SetRequestMethod([]byte)
+	ShouldKeepAlive() bool
+	Clear()
+}
+
+type httpResponseParser struct {
+	protocol      ResponseProtocol
+	onKnownHeader func(id HeaderID, value []byte) error
+	settings      Settings
+
+	state            parsingState
+	headersParser    *headersParser
+	startLineBuff    []byte
+	startLineOffset  uint
+	statusCode       int
+	statusCodeDigits int // leading zeroes included
+	requestMethod    []byte
+	upgradeBuff      []byte
+
+	persistentByDefault bool
+	persistent          bool
+
+	bodyParser
+	connPosition
+}
+
+/*
+	Returns new initialized instance of responses parser. It uses the same settings,
+	headers state machine and chunked body parser as requests parser does
+*/
+func NewHTTPResponseParser(protocol ResponseProtocol, settings Settings) *httpResponseParser {
+	settings = PrepareSettings(settings)
+
+	parser := &httpResponseParser{
+		protocol:      protocol,
+		settings:      settings,
+		startLineBuff: settings.StartLineBuffer,
+		bodyParser:    newBodyParser(protocol, settings),
+		state:         responseProtocol,
+	}
+	parser.headersParser = newHeadersParser(parser.onHeader, settings.HeadersBuffer, settings)
+
+	if knownHeadersProtocol, ok := protocol.(KnownHeadersProtocol); ok {
+		parser.onKnownHeader = knownHeadersProtocol.OnKnownHeader
+	}
+
+	return parser
+}
+
+/*
+	Body framing of the response depends on the request it answers: responses to HEAD
+	never have a body, and successful responses to CONNECT switch the connection to
+	a tunnel. Passed method is applied to the response that is currently parsed (or
+	to the next one, if none is parsed now) and is forgotten as soon as the final
+	(non-informational) response is completed
+*/
+func (p *httpResponseParser) SetRequestMethod(method []byte) {
+	p.requestMethod = append(p.requestMethod[:0], method...)
+}
+
+func (p *httpResponseParser) Clear() {
+	p.state = responseProtocol
+	p.headersParser.Clear()
+	p.bodyParser.Clear()
+	p.startLineBuff = p.startLineBuff[:0]
+	p.startLineOffset = 0
+	p.statusCode = 0
+	p.statusCodeDigits = 0
+	p.upgradeBuff = p.upgradeBuff[:0]
+}
+
+/*
+	Feeds the parser in the same way requests parser is fed. In case response has
+	neither Content-Length nor chunked Transfer-Encoding, its body ends with the
+	connection, so empty data must be fed when the connection is closed
+*/
+func (p *httpResponseParser) Feed(data []byte) error {
+	p.feeding(data)
+
+	return p.feed(data)
+}
+
+func (p *httpResponseParser) feed(data []byte) (respErr error) {
+	if len(data) == 0 {
+		if p.state == bodyConnectionClose {
+			p.die()
+
+			if respErr = p.protocol.OnMessageComplete(); respErr != nil {
+				return respErr
+			}
+
+			return ErrConnectionClosed
+		}
+
+		return nil
+	}
+
+	if p.state == dead {
+		return ErrParserIsDead
+	}
+
+	for i := 0; i < len(data); i++ {
+		switch p.state {
+		case responseProtocol:
+			if data[i] == ' ' {
+				if !IsProtocolSupported(p.startLineBuff) {
+					return p.fail(ErrProtocolNotSupported, data, i)
+				}
+
+				if err := checkProtocolCase(p.startLineBuff, p.settings.Strictness); err != nil {
+					return p.fail(err, data, i)
+				}
+
+				if respErr = p.protocol.OnProtocol(p.startLineBuff); respErr != nil {
+					p.die()
+
+					return respErr
+				}
+
+				p.persistentByDefault = isPersistentByDefault(p.startLineBuff)
+
+				p.startLineOffset = uint(len(p.startLineBuff))
+				p.state = statusCode
+				break
+			}
+
+			if !isProtocolChar(data[i]) {
+				// whatever it is, it isn't a response
+				return p.fail(ErrProtocolNotSupported, data, i)
+			}
+
+			p.startLineBuff = append(p.startLineBuff, data[i])
+
+			if len(p.startLineBuff) > maxProtocolLength {
+				return p.fail(ErrProtocolOverflow, data, i)
+			}
+		case statusCode:
+			switch data[i] {
+			case ' ', '\r', '\n':
+				if data[i] == '\n' && p.settings.Strictness.has(RejectBareLF) {
+					return p.fail(ErrBareLF, data, i)
+				}
+
+				if p.statusCodeDigits != 3 || p.statusCode < 100 {
+					return p.fail(ErrInvalidStatusCode, data, i)
+				}
+
+				if respErr = p.protocol.OnStatusCode(p.statusCode); respErr != nil {
+					p.die()
+
+					return respErr
+				}
+
+				switch data[i] {
+				case ' ':
+					p.state = reasonPhrase
+				case '\r':
+					p.state = reasonPhraseCR
+				case '\n':
+					if respErr = p.onStatusLineComplete(); respErr != nil {
+						return respErr
+					}
+				}
+			default:
+				if data[i] < '0' || data[i] > '9' {
+					return p.fail(ErrInvalidStatusCode, data, i)
+				}
+
+				if p.statusCodeDigits++; p.statusCodeDigits > 3 {
+					return p.fail(ErrInvalidStatusCode, data, i)
+				}
+
+				p.statusCode = p.statusCode*10 + int(data[i]-'0')
+			}
+		case reasonPhrase:
+			switch data[i] {
+			case '\r':
+				p.state = reasonPhraseCR
+			case '\n':
+				if p.settings.Strictness.has(RejectBareLF) {
+					return p.fail(ErrBareLF, data, i)
+				}
+
+				if respErr = p.onStatusLineComplete(); respErr != nil {
+					return respErr
+				}
+			default:
+				if !ascii.IsPrint(data[i]) && data[i] != '\t' {
+					return p.fail(ErrInvalidReason, data, i)
+				}
+
+				p.startLineBuff = append(p.startLineBuff, data[i])
+
+				if len(p.startLineBuff[p.startLineOffset:]) > maxReasonLength {
+					return p.fail(ErrReasonOverflow, data, i)
+				}
+			}
+		case reasonPhraseCR:
+			if data[i] != '\n' {
+				return p.fail(ErrBareCR, data, i)
+			}
+
+			if respErr = p.onStatusLineComplete(); respErr != nil {
+				return respErr
+			}
+		case headers:
+			done, extra, err := p.headersParser.Feed(data[i:])
+
+			if err != nil {
+				return p.failIn(err, i)
+			}
+
+			if !done {
+				return nil
+			}
+
+			if respErr = p.onHeadersComplete(extra); respErr != nil {
+				return respErr
+			}
+
+			if len(extra) > 0 {
+				p.skip(data, extra)
+
+				return p.feed(extra)
+			}
+
+			return nil
+		case body:
+			done, extra, err := p.pushBodyPiece(data[i:])
+
+			if err != nil {
+				return p.failIn(err, i)
+			}
+
+			if done {
+				if respErr = p.completeMessage(); respErr != nil {
+					return respErr
+				}
+
+				if len(extra) > 0 {
+					p.skip(data, extra)
+
+					return p.feed(extra)
+				}
+			}
+
+			return nil
+		case bodyConnectionClose:
+			p.bodyBytesLeft -= len(data[i:])
+
+			if p.bodyBytesLeft < 0 {
+				return p.fail(ErrBodyTooBig, data, len(data)+p.bodyBytesLeft)
+			}
+
+			if respErr = p.protocol.OnBody(data[i:]); respErr != nil {
+				p.die()
+
+				return respErr
+			}
+
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (p *httpResponseParser) onStatusLineComplete() error {
+	if err := p.protocol.OnReason(p.startLineBuff[p.startLineOffset:]); err != nil {
+		p.die()
+
+		return err
+	}
+
+	p.state = headers
+
+	return nil
+}
+
+func (p *httpResponseParser) onHeader(key, value []byte) error {
+	if err := p.protocol.OnHeader(key, value); err != nil {
+		return err
+	}
+
+	id := lookupHeader(key)
+
+	if id != HeaderUnknown && p.onKnownHeader != nil {
+		if err := p.onKnownHeader(id, value); err != nil {
+			return err
+		}
+	}
+
+	if id == HeaderUpgrade {
+		if len(p.upgradeBuff) > 0 {
+			p.upgradeBuff = append(p.upgradeBuff, ',')
+		}
+
+		p.upgradeBuff = append(p.upgradeBuff, value...)
+	}
+
+	if err := p.inspectHeader(id, value); err != nil {
+		// position is filled by headers parser
+		return &ParseError{Err: err}
+	}
+
+	return nil
+}
+
+func (p *httpResponseParser) onHeadersComplete(extra []byte) error {
+	if _, err := p.pushTransferCodings(0); err != nil {
+		p.die()
+
+		return err
+	}
+
+	p.persistent = p.keepAlive(p.persistentByDefault)
+
+	if p.isUpgrade() {
+		return p.upgrade(extra)
+	}
+
+	switch {
+	case p.hasNoBody():
+		return p.completeMessage()
+	case p.isChunked:
+		p.state = body
+	case p.hasTransferEncoding:
+		// chunked is not the final coding, so body is delimited by the connection close
+		p.state = bodyConnectionClose
+		p.bodyBytesLeft = p.settings.MaxBodyLength
+		p.persistent = false
+	case p.hasContentLength:
+		if p.bodyBytesLeft == 0 {
+			return p.completeMessage()
+		}
+
+		p.state = body
+	default:
+		// body is delimited by the connection close
+		p.state = bodyConnectionClose
+		p.bodyBytesLeft = p.settings.MaxBodyLength
+		p.persistent = false
+	}
+
+	return nil
+}
+
+/*
+	Responses to HEAD requests, and 1xx, 204 and 304 responses never have a body,
+	whatever their headers say
+*/
+func (p *httpResponseParser) hasNoBody() bool {
+	switch {
+	case p.statusCode < 200, p.statusCode == 204, p.statusCode == 304:
+		return true
+	case bytes.Equal(p.requestMethod, HEAD):
+		return true
+	}
+
+	return false
+}
+
+/*
+	After 101 Switching Protocols, or successful response to CONNECT request, the
+	connection doesn't carry HTTP anymore
+*/
+func (p *httpResponseParser) isUpgrade() bool {
+	switch {
+	case p.statusCode == 101:
+		return true
+	case bytes.Equal(p.requestMethod, CONNECT):
+		return p.statusCode >= 200 && p.statusCode < 300
+	}
+
+	return false
+}
+
+/*
+	Parsing stops right after headers, as everything after them belongs to another
+	protocol (or to a tunnel), so it is returned to the client within Upgrade. As
+	well as requests parser does, protocol may return its own Upgrade from
+	OnMessageComplete()
+*/
+func (p *httpResponseParser) upgrade(rest []byte) error {
+	upgrade := newUpgrade(string(p.upgradeBuff))
+	p.requestMethod = p.requestMethod[:0]
+	p.Clear()
+	p.messageIndex++
+
+	switch err := p.protocol.OnMessageComplete().(type) {
+	case nil:
+	case Upgrade:
+		upgrade = err
+	default:
+		p.die()
+
+		return err
+	}
+
+	upgrade.Rest = rest
+
+	return upgrade
+}
+
+/*
+	Returns whether connection may be reused for the next request, according to
+	Connection header, protocol version and body framing of the response. Result
+	is available since headers of the response are completed, and until headers
+	of the next one are completed
+*/
+func (p *httpResponseParser) ShouldKeepAlive() bool {
+	return p.persistent
+}
+
+func (p *httpResponseParser) completeMessage() error {
+	if p.statusCode >= 200 {
+		// informational responses are followed by the final one to the same request
+		p.requestMethod = p.requestMethod[:0]
+	}
+
+	forceClose := p.forceClose
+	p.Clear()
+	p.messageIndex++
+
+	if err := p.protocol.OnMessageComplete(); err != nil {
+		p.die()
+
+		return err
+	}
+
+	if forceClose {
+		p.die()
+
+		return ErrConnectionClosed
+	}
+
+	return nil
+}
+
+func (p *httpResponseParser) fail(err error, data []byte, i int) error {
+	return p.failIn(newParseError(err, data, i), 0)
+}
+
+func (p *httpResponseParser) failIn(err error, offset int) error {
+	err = p.locate(err, p.state, offset)
+	p.die()
+
+	return err
+}
+
+func (p *httpResponseParser) die() {
+	p.state = dead
+	p.headersParser.buffer = nil
+	p.startLineBuff = nil
+}
@@ -4,12 +4,25 @@ import "math"
 
 const (
 	// hard limits
-	maxMethodLength     = 7
-	maxProtocolLength   = 10
-	maxPathLength       = 4092           // rfc says that 65535, but it's too expensive - set it by yourself if you want
-	maxHeaderLineLength = 4092           // idk what rfc says here, but this is also enough in MOST cases
-	maxBodyLength       = math.MaxInt32  // 2147483647
-	maxChunkLength      = math.MaxUint16 // 65535
+	maxMethodLength      = 7
+	maxProtocolLength    = 10
+	maxReasonLength      = 512
+	maxPathLength        = 4092           // rfc says that 65535, but it's too expensive - set it by yourself if you want
+	maxHeaderLineLength  = 4092           // idk what rfc says here, but this is also enough in MOST cases
+	maxHeadersCount      = 100            // the same as apache's LimitRequestFields
+	maxHeaderSectionSize = math.MaxUint16 // 65535, including CRLFs
+	maxBodyLength        = math.MaxInt32  // 2147483647
+	maxChunkLength       = math.MaxUint16 // 65535
+
+	// leading zeroes of chunk size are counted too. 15 hex digits never overflow int64
+	maxChunkSizeDigits = 15
+	// chunk extensions are pretty rare, so there is no need to let them be long
+	maxChunkExtensionsLength = 1024
+	// in case any token is accepted as a method
+	maxTokenMethodLength = 32
+	// applied only in case protocol implements OnQueryParam()
+	maxQueryParams      = 128
+	maxQueryParamLength = 1024
 )
 
 const (
@@ -21,10 +34,21 @@ const (
 
 type Settings struct {
 	// hard limits
+	MaxMethodLength     int
 	MaxPathLength       int
 	MaxHeaderLineLength int
-	MaxBodyLength       int
-	MaxChunkLength      int
+	// limits of the whole header section (and trailers of chunked body as well), as a
+	// lot of small headers are as expensive as a single long one
+	MaxHeadersCount      int
+	MaxHeaderSectionSize int
+	MaxBodyLength        int
+	MaxChunkLength       int
+	// total length of all the extensions of a single chunk
+	MaxChunkExtensionsLength int
+	// limits of the query parameters passed to OnQueryParam(). Length is of the
+	// encoded key=value pair
+	MaxQueryParams      int
+	MaxQueryParamLength int
 
 	// soft limits
 	InitialPathBufferLength    int
@@ -32,21 +56,70 @@ type Settings struct {
 
 	StartLineBuffer []byte
 	HeadersBuffer   []byte
+
+	// methods that are accepted along with the standard ones, e.g. PROPFIND or PURGE
+	ExtraMethods [][]byte
+	// accept any token as a method. Unknown methods are still limited by MaxMethodLength
+	TokenMethods bool
+
+	// percent-decode unreserved characters of the path, and remove dot segments and
+	// empty segments from it. Normalized path is passed to OnURLPath()
+	NormalizePath bool
+
+	// how strictly the syntax of messages is checked, e.g. httpparser.Strict
+	Strictness Strictness
+
+	// reject messages with both Content-Length and Transfer-Encoding, or with
+	// duplicated Content-Length headers, instead of closing connection after them.
+	// Implies RejectWhitespaceBeforeColon strictness flag
+	StrictFraming bool
+
+	// complete the request right away in case OnExpectContinue() rejects it, and
+	// close the connection after it, instead of skipping the body
+	CloseOnRejectedExpectation bool
 }
 
 func PrepareSettings(settings Settings) Settings {
+	if settings.MaxMethodLength < 1 {
+		settings.MaxMethodLength = maxMethodLength
+
+		if settings.TokenMethods {
+			settings.MaxMethodLength = maxTokenMethodLength
+		}
+
+		for _, method := range settings.ExtraMethods {
+			if len(method) > settings.MaxMethodLength {
+				settings.MaxMethodLength = len(method)
+			}
+		}
+	}
 	if settings.MaxPathLength < 1 {
 		settings.MaxPathLength = maxPathLength
 	}
 	if settings.MaxHeaderLineLength < 1 {
 		settings.MaxHeaderLineLength = maxHeaderLineLength
 	}
+	if settings.MaxHeadersCount < 1 {
+		settings.MaxHeadersCount = maxHeadersCount
+	}
+	if settings.MaxHeaderSectionSize < 1 {
+		settings.MaxHeaderSectionSize = maxHeaderSectionSize
+	}
 	if settings.MaxBodyLength < 1 {
 		settings.MaxBodyLength = maxBodyLength
 	}
 	if settings.MaxChunkLength < 1 {
 		settings.MaxChunkLength = maxChunkLength
 	}
+	if settings.MaxChunkExtensionsLength < 1 {
+		settings.MaxChunkExtensionsLength = maxChunkExtensionsLength
+	}
+	if settings.MaxQueryParams < 1 {
+		settings.MaxQueryParams = maxQueryParams
+	}
+	if settings.MaxQueryParamLength < 1 {
+		settings.MaxQueryParamLength = maxQueryParamLength
+	}
 
 	if settings.InitialPathBufferLength < 1 {
 		settings.InitialPathBufferLength = initialPathBufferLength
@@ -55,9 +128,14 @@ func PrepareSettings(settings Settings) Settings {
 		settings.InitialHeadersBufferLength = initialHeadersBufferLength
 	}
 
+	if settings.StrictFraming {
+		// otherwise "Transfer-Encoding : chunked" is just an unknown header
+		settings.Strictness |= RejectWhitespaceBeforeColon
+	}
+
 	if settings.StartLineBuffer == nil {
 		// but user still can pass just an empty buffer with capacity he needs
-		initialLength := settings.InitialPathBufferLength + maxMethodLength + maxProtocolLength
+		initialLength := settings.InitialPathBufferLength + settings.MaxMethodLength + maxProtocolLength
 		settings.StartLineBuffer = make([]byte, 0, initialLength)
 	}
 	if settings.HeadersBuffer == nil {
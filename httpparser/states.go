@@ -2,6 +2,8 @@ package httpparser
 
 type (
 	parsingState     uint8
+	parsingStep      uint8
+	headersState     uint8
 	chunkedBodyState uint8
 )
 
@@ -12,28 +14,123 @@ const (
 	protocol
 	protocolCR
 	protocolLF
+	headers
+	body
+	bodyConnectionClose
+	tunnel
+
+	responseProtocol
+	statusCode
+	reasonPhrase
+	reasonPhraseCR
+
+	dead
+)
+
+/*
+	Callbacks of requests parser that follow each other without parsing anything in
+	between. Every step calls a single callback (at most), so in case it returns
+	ErrPause, the rest of them are called only after Resume()
+*/
+const (
+	noStep parsingStep = iota
+	stepHeadersBegin
+	stepAuthority
+	stepAbsoluteTarget
+	stepURLPath
+	stepQueryString
+	stepQueryParams
+	stepFragment
+	stepKnownHeader
+	stepTransferCodings
+	stepHeadersComplete
+	stepBodyBegin
+	stepMessageComplete
+	stepMessageBegin
+)
+
+const (
+	headersBegin headersState = iota + 1
 	headerKey
 	headerColon
 	headerValue
 	headerValueCR
 	headerValueLF
 	headerValueDoubleCR
-	body
-	bodyConnectionClose
-
-	dead
+	// whitespaces that begin continuation line of the folded header
+	headerObsFold
 )
 
 const (
 	chunkLength chunkedBodyState = iota + 1
 	chunkLengthCR
 
+	chunkExtensionBegin
+	chunkExtensionName
+	chunkExtensionNameEnd
+	chunkExtensionValue
+	chunkExtensionTokenValue
+	chunkExtensionQuotedValue
+	chunkExtensionQuotedPair
+	chunkExtensionValueEnd
+
 	chunkBody
 	chunkBodyEnd
 	chunkBodyCR
 
 	lastChunk
-	lastChunkCR
 
 	transferCompleted
 )
+
+var parsingStateNames = [...]string{
+	messageBegin:        "messageBegin",
+	method:              "method",
+	path:                "path",
+	protocol:            "protocol",
+	protocolCR:          "protocolCR",
+	protocolLF:          "protocolLF",
+	headers:             "headers",
+	body:                "body",
+	bodyConnectionClose: "bodyConnectionClose",
+	tunnel:              "tunnel",
+	responseProtocol:    "responseProtocol",
+	statusCode:          "statusCode",
+	reasonPhrase:        "reasonPhrase",
+	reasonPhraseCR:      "reasonPhraseCR",
+	dead:                "dead",
+}
+
+func (s parsingState) String() string {
+	if int(s) >= len(parsingStateNames) || len(parsingStateNames[s]) == 0 {
+		return "unknown"
+	}
+
+	return parsingStateNames[s]
+}
+
+var chunkedBodyStateNames = [...]string{
+	chunkLength:               "chunkLength",
+	chunkLengthCR:             "chunkLengthCR",
+	chunkExtensionBegin:       "chunkExtensionBegin",
+	chunkExtensionName:        "chunkExtensionName",
+	chunkExtensionNameEnd:     "chunkExtensionNameEnd",
+	chunkExtensionValue:       "chunkExtensionValue",
+	chunkExtensionTokenValue:  "chunkExtensionTokenValue",
+	chunkExtensionQuotedValue: "chunkExtensionQuotedValue",
+	chunkExtensionQuotedPair:  "chunkExtensionQuotedPair",
+	chunkExtensionValueEnd:    "chunkExtensionValueEnd",
+	chunkBody:                 "chunkBody",
+	chunkBodyEnd:              "chunkBodyEnd",
+	chunkBodyCR:               "chunkBodyCR",
+	lastChunk:                 "lastChunk",
+	transferCompleted:         "transferCompleted",
+}
+
+func (s chunkedBodyState) String() string {
+	if int(s) >= len(chunkedBodyStateNames) || len(chunkedBodyStateNames[s]) == 0 {
+		return "unknown"
+	}
+
+	return chunkedBodyStateNames[s]
+}
@@ -0,0 +1,58 @@
+package httpparser
+
+import (
+	"errors"
+	"io"
+	"strconv"
+)
+
+var statusReasons = map[int]string{
+	400: "Bad Request",
+	413: "Content Too Large",
+	414: "URI Too Long",
+	431: "Request Header Fields Too Large",
+	501: "Not Implemented",
+	505: "HTTP Version Not Supported",
+}
+
+/*
+	Returns status code of the response that must be sent to the client in case
+	requests parser returned the error. Errors returned by callbacks are considered
+	client's fault too, so the status is 400 for them
+*/
+func ErrorStatusCode(err error) int {
+	switch {
+	case errors.Is(err, ErrPathOverflow), errors.Is(err, ErrQueryOverflow):
+		return 414
+	case errors.Is(err, ErrHeaderOverflow), errors.Is(err, ErrTooManyHeaders),
+		errors.Is(err, ErrHeaderSectionOverflow):
+		return 431
+	case errors.Is(err, ErrBodyTooBig), errors.Is(err, ErrTooBigChunkSize):
+		return 413
+	case errors.Is(err, ErrUnknownMethod), errors.Is(err, ErrUnsupportedTransferEncoding):
+		return 501
+	case errors.Is(err, ErrProtocolNotSupported):
+		return 505
+	default:
+		return 400
+	}
+}
+
+/*
+	Writes minimal valid response with the status code that corresponds to the error.
+	Connection is closed after it anyway, as parser is dead after the error
+*/
+func WriteErrorResponse(w io.Writer, err error) error {
+	code := ErrorStatusCode(err)
+
+	response := make([]byte, 0, 128)
+	response = append(response, "HTTP/1.1 "...)
+	response = strconv.AppendInt(response, int64(code), 10)
+	response = append(response, ' ')
+	response = append(response, statusReasons[code]...)
+	response = append(response, "\r\nContent-Length: 0\r\nConnection: close\r\n\r\n"...)
+
+	_, err = w.Write(response)
+
+	return err
+}
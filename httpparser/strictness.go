@@ -0,0 +1,55 @@
+package httpparser
+
+/*
+	Set of flags that control how strictly the syntax of messages is checked. Zero
+	value keeps the default behaviour, that is somewhere between strict and lenient
+	ones. Flags of the start line are applied to both requests and responses, except
+	AllowMultipleSpaces, that is applied to requests only
+*/
+type Strictness uint16
+
+const (
+	// reject lines that are terminated by LF without CR (RFC 9112, section 2.2)
+	RejectBareLF Strictness = 1 << iota
+	// replace CR that isn't followed by LF in header values with a space, instead
+	// of rejecting it
+	AllowBareCR
+	// reject headers with whitespaces between the key and the colon (RFC 9112,
+	// section 5.1). By default, they are kept as a part of the key
+	RejectWhitespaceBeforeColon
+	// accept protocol in upper case only, e.g. HTTP/1.1, but not http/1.1
+	RejectLowercaseProtocol
+	// skip extra spaces between method, request-target and protocol, instead of rejecting them
+	AllowMultipleSpaces
+	// remove trailing whitespaces of header values as well. Leading ones are
+	// always skipped
+	TrimHeaderValues
+	// header lines that begin with a whitespace continue the previous header (RFC
+	// 9112, section 5.2). By default, they are rejected, but with this flag the fold
+	// is replaced with a single space, and the header is passed to the callback
+	// only once it's completed
+	UnfoldObsFold
+)
+
+const (
+	// what RFC 9112 requires from recipients
+	Strict = RejectBareLF | RejectWhitespaceBeforeColon | RejectLowercaseProtocol | TrimHeaderValues
+	// accept whatever may be understood
+	Lenient = AllowBareCR | AllowMultipleSpaces | TrimHeaderValues | UnfoldObsFold
+)
+
+func (s Strictness) has(flag Strictness) bool {
+	return s&flag != 0
+}
+
+/*
+	Protocols are case-sensitive, but lower-cased ones are accepted unless strictness
+	rejects them
+*/
+func checkProtocolCase(proto []byte, strictness Strictness) error {
+	if strictness.has(RejectLowercaseProtocol) && len(proto) > 0 && proto[0] == 'h' {
+		return ErrLowercaseProtocol
+	}
+
+	return nil
+}
@@ -0,0 +1,193 @@
+package httpparser
+
+import "bytes"
+
+/*
+	Forms of request-target (RFC 9112, section 3.2)
+*/
+type RequestTargetForm uint8
+
+const (
+	// /path?query
+	OriginForm RequestTargetForm = iota + 1
+	// http://host:port/path?query
+	AbsoluteForm
+	// host:port, used only by CONNECT
+	AuthorityForm
+	// *, used only by server-wide OPTIONS
+	AsteriskForm
+)
+
+var (
+	schemeSeparator = []byte("://")
+	emptyPath       = []byte("/")
+)
+
+/*
+	Components of the request-target. Query and fragment are found while path is
+	scanned, everything else is found once request-target is completed. Offsets are
+	relative to the beginning of request-target, and zero offset means there's no
+	such a component
+*/
+type requestTarget struct {
+	form          RequestTargetForm
+	scheme        []byte
+	host          []byte
+	port          []byte
+	pathBegin     int
+	queryBegin    int
+	fragmentBegin int
+
+	// filled by splitComponents() only
+	path     []byte
+	query    []byte
+	fragment []byte
+
+	// scratch buffer for the normalized path, it outlives Clear()
+	normalizedPath []byte
+	normalized     bool
+}
+
+func (r *requestTarget) Clear() {
+	*r = requestTarget{normalizedPath: r.normalizedPath[:0]}
+}
+
+/*
+	Must be called for every byte of request-target, i is its offset in request-target
+*/
+func (r *requestTarget) scan(char byte, i int) {
+	switch char {
+	case '?':
+		if r.queryBegin == 0 && r.fragmentBegin == 0 {
+			r.queryBegin = i
+		}
+	case '#':
+		if r.fragmentBegin == 0 {
+			r.fragmentBegin = i
+		}
+	}
+}
+
+/*
+	Finds out the form of the completed request-target. Anything that isn't one of
+	the four forms, or the form that isn't allowed for the method, is invalid
+*/
+func (r *requestTarget) parse(target, method []byte) (err error) {
+	switch {
+	case bytes.Equal(method, CONNECT):
+		r.form = AuthorityForm
+		r.host, r.port, err = parseAuthority(target)
+
+		return err
+	case target[0] == '/':
+		r.form = OriginForm
+	case len(target) == 1 && target[0] == '*':
+		// server-wide OPTIONS is the only request that isn't applied to a resource
+		if !bytes.Equal(method, OPTIONS) {
+			return ErrInvalidPath
+		}
+
+		r.form = AsteriskForm
+	default:
+		r.form = AbsoluteForm
+
+		return r.parseAbsolute(target)
+	}
+
+	return nil
+}
+
+/*
+	absolute-form = scheme "://" authority path-abempty [ "?" query ]
+*/
+func (r *requestTarget) parseAbsolute(target []byte) (err error) {
+	schemeEnd := bytes.Index(target, schemeSeparator)
+
+	if schemeEnd < 1 || !isScheme(target[:schemeEnd]) {
+		return ErrInvalidPath
+	}
+
+	r.scheme = target[:schemeEnd]
+	authorityBegin := schemeEnd + len(schemeSeparator)
+	r.pathBegin = len(target)
+
+	if end := bytes.IndexAny(target[authorityBegin:], "/?#"); end != -1 {
+		r.pathBegin = authorityBegin + end
+	}
+
+	if r.host, r.port, err = splitHostPort(target[authorityBegin:r.pathBegin]); err != nil {
+		return ErrInvalidPath
+	}
+
+	return nil
+}
+
+/*
+	Splits path of origin-form or absolute-form request-target into path itself, query
+	and fragment. Query and fragment are nil in case they aren't presented at all. Empty
+	path of absolute-form is the same as "/"
+*/
+func (r *requestTarget) split(target []byte) (path, query, fragment []byte) {
+	end := len(target)
+
+	if r.fragmentBegin > 0 {
+		fragment = target[r.fragmentBegin+1:]
+		end = r.fragmentBegin
+	}
+	if r.queryBegin > 0 {
+		query = target[r.queryBegin+1 : end]
+		end = r.queryBegin
+	}
+
+	if path = target[r.pathBegin:end]; len(path) == 0 {
+		path = emptyPath
+	}
+
+	return path, query, fragment
+}
+
+/*
+	Same as split(), but components are kept until Clear(). Normalized path, if any,
+	replaces the raw one
+*/
+func (r *requestTarget) splitComponents(target []byte) {
+	r.path, r.query, r.fragment = r.split(target)
+
+	if r.normalized {
+		r.path = r.normalizedPath
+	}
+}
+
+/*
+	Normalizes path of origin-form or absolute-form request-target. Raw request-target
+	stays as is
+*/
+func (r *requestTarget) normalize(target []byte) (err error) {
+	if r.form != OriginForm && r.form != AbsoluteForm {
+		return nil
+	}
+
+	path, _, _ := r.split(target)
+	r.normalizedPath, err = normalizePath(r.normalizedPath, path)
+	r.normalized = err == nil
+
+	return err
+}
+
+/*
+	scheme = ALPHA *( ALPHA / DIGIT / "+" / "-" / "." )
+*/
+func isScheme(scheme []byte) bool {
+	for i, char := range scheme {
+		lower := char | 0x20
+
+		switch {
+		case lower >= 'a' && lower <= 'z':
+		case i > 0 && (char >= '0' && char <= '9' || char == '+' || char == '-' || char == '.'):
+		default:
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,68 @@
+package httpparser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fakefloordiv/snowdrop-http/httpparser"
+)
+
+func TestMaxBodyLengthContentLength(t *testing.T) {
+	settings := httpparser.Settings{MaxBodyLength: 5}
+	parser, _ := httpparser.NewHTTPRequestParser(&Protocol{}, settings)
+
+	if err := parser.Feed([]byte("POST / HTTP/1.1\r\nContent-Length: 5\r\n\r\nhello")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	protocol := Protocol{}
+	parser, _ = httpparser.NewHTTPRequestParser(&protocol, settings)
+	request := "POST / HTTP/1.1\r\nContent-Length: 99999999999\r\n\r\n"
+	err := parser.Feed([]byte(request))
+
+	var parseErr *httpparser.ParseError
+
+	switch {
+	case !errors.Is(err, httpparser.ErrBodyTooBig):
+		t.Fatalf("expected ErrBodyTooBig before body begins, got %v", err)
+	case !errors.As(err, &parseErr) || parseErr.State != "headers":
+		t.Fatalf("expected ParseError in headers state, got %v", err)
+	case protocol.Body != nil:
+		t.Fatalf("body must not be pushed, got %s", quote(protocol.Body))
+	case httpparser.ErrorStatusCode(err) != 413:
+		t.Fatalf("wanted 413, got %d", httpparser.ErrorStatusCode(err))
+	}
+}
+
+func TestMaxBodyLengthChunked(t *testing.T) {
+	settings := httpparser.Settings{MaxBodyLength: 10}
+	head := "POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n"
+
+	for i := 1; i <= 64; i++ {
+		parser, _ := httpparser.NewHTTPRequestParser(&Protocol{}, settings)
+
+		if err := FeedParser(parser, []byte(head+"5\r\nhello\r\n5\r\nworld\r\n0\r\n\r\n"), i); err != nil {
+			t.Fatalf("feeding by %d: unexpected error: %s", i, err)
+		}
+
+		protocol := Protocol{}
+		parser, _ = httpparser.NewHTTPRequestParser(&protocol, settings)
+		err := FeedParser(parser, []byte(head+"5\r\nhello\r\n5\r\nworld\r\n1\r\n!\r\n0\r\n\r\n"), i)
+
+		if !errors.Is(err, httpparser.ErrBodyTooBig) {
+			t.Fatalf("feeding by %d: expected ErrBodyTooBig, got %v", i, err)
+		} else if string(protocol.Body) != "helloworld" {
+			t.Fatalf("feeding by %d: exceeding chunk must not be pushed, got %s", i, quote(protocol.Body))
+		}
+	}
+}
+
+func TestMaxBodyLengthChunkedPerMessage(t *testing.T) {
+	settings := httpparser.Settings{MaxBodyLength: 5}
+	request := "POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n0\r\n\r\n"
+	parser, _ := httpparser.NewHTTPRequestParser(&Protocol{}, settings)
+
+	if err := parser.Feed([]byte(request + request + request)); err != nil {
+		t.Fatalf("limit must be applied to every body separately: %s", err)
+	}
+}
@@ -1,6 +1,8 @@
 package httpparser
 
 import (
+	"errors"
+	"strings"
 	"testing"
 
 	"github.com/fakefloordiv/snowdrop-http/httpparser"
@@ -133,7 +135,7 @@ func TestChunkOverflow(t *testing.T) {
 		return
 	}
 
-	if err != httpparser.ErrInvalidChunkSplitter {
+	if !errors.Is(err, httpparser.ErrInvalidChunkSplitter) {
 		t.Errorf(`expected InvalidChunkSplitter error, got msg="%s"`, err.Error())
 		return
 	}
@@ -156,7 +158,7 @@ func TestChunkTooSmall(t *testing.T) {
 		return
 	}
 
-	if err != httpparser.ErrInvalidChunkSplitter {
+	if !errors.Is(err, httpparser.ErrInvalidChunkSplitter) {
 		t.Errorf(`expected InvalidChunkSplitter error, got msg="%s"`, err.Error())
 		return
 	}
@@ -208,3 +210,150 @@ func TestWithDifferentBlockSizes(t *testing.T) {
 		}
 	}
 }
+
+type TrailersProtocol struct {
+	Protocol
+	Trailers map[string]string
+}
+
+func (p *TrailersProtocol) OnTrailer(key, value []byte) error {
+	if p.Trailers == nil {
+		p.Trailers = make(map[string]string)
+	}
+
+	p.Trailers[string(key)] = string(value)
+
+	return nil
+}
+
+func TestChunkedTrailers(t *testing.T) {
+	request := []byte("POST / HTTP/1.1\r\n" +
+		"Transfer-Encoding: chunked\r\n" +
+		"Trailer: Expires, Grpc-Status\r\n" +
+		"\r\nd\r\nHello, world!\r\n0\r\n" +
+		"Expires: Wed, 21 Oct 2015 07:28:00 GMT\r\nGrpc-Status: 0\r\n\r\n" +
+		"GET / HTTP/1.1\r\n\r\n")
+
+	for i := 1; i <= len(request); i++ {
+		protocol := TrailersProtocol{}
+		parser, _ := httpparser.NewHTTPRequestParser(&protocol, httpparser.Settings{})
+
+		if err := FeedParser(parser, request, i); err != nil {
+			t.Fatalf("feeding by %d: unexpected error: %s", i, err)
+		}
+
+		if protocol.CompletedTimes != 2 {
+			t.Fatalf("feeding by %d: wanted 2 completions, got %d", i, protocol.CompletedTimes)
+		} else if protocol.Trailers["Expires"] != "Wed, 21 Oct 2015 07:28:00 GMT" ||
+			protocol.Trailers["Grpc-Status"] != "0" {
+			t.Fatalf("feeding by %d: unexpected trailers: %v", i, protocol.Trailers)
+		}
+	}
+}
+
+func TestChunkedTrailersSkipped(t *testing.T) {
+	protocol := Protocol{}
+	parser := httpparser.NewChunkedBodyParser(protocol.OnBody, 65535)
+	data := []byte("d\r\nHello, world!\r\n0\r\nGrpc-Status: 0\nGrpc-Message: ok\r\n\r\nextra")
+
+	done, extra, err := parser.Feed(data)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if !done {
+		t.Fatal("no completion flag")
+	} else if string(extra) != "extra" {
+		t.Fatalf("wanted extra-bytes, got %s", quote(extra))
+	} else if string(protocol.Body) != "Hello, world!" {
+		t.Fatalf("unexpected body: %s", quote(protocol.Body))
+	}
+}
+
+func TestChunkedTrailerTooLong(t *testing.T) {
+	protocol := TrailersProtocol{}
+	parser, _ := httpparser.NewHTTPRequestParser(&protocol, httpparser.Settings{
+		MaxHeaderLineLength: 32,
+	})
+	request := []byte("POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n" +
+		"X-Trailer: " + strings.Repeat("a", 32) + "\r\n\r\n")
+
+	if err := parser.Feed(request); !errors.Is(err, httpparser.ErrBufferOverflow) {
+		t.Fatalf("expected ErrBufferOverflow, got %v", err)
+	}
+}
+
+type ChunkExtensionsProtocol struct {
+	Protocol
+	Extensions []string
+}
+
+func (p *ChunkExtensionsProtocol) OnChunkExtension(name, value []byte) error {
+	p.Extensions = append(p.Extensions, string(name)+"="+string(value))
+
+	return nil
+}
+
+func TestChunkExtensions(t *testing.T) {
+	request := []byte("POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n" +
+		"d;name=value ; flag;q = \"quoted \\\"str\\\"\"\r\nHello, world!\r\n0;last\r\n\r\n")
+	extensionsExpected := []string{"name=value", "flag=", "q=quoted \"str\"", "last="}
+
+	for i := 1; i <= len(request); i++ {
+		protocol := ChunkExtensionsProtocol{}
+		parser, _ := httpparser.NewHTTPRequestParser(&protocol, httpparser.Settings{})
+
+		if err := FeedParser(parser, request, i); err != nil {
+			t.Fatalf("feeding by %d: unexpected error: %s", i, err)
+		} else if protocol.CompletedTimes != 1 {
+			t.Fatalf("feeding by %d: no completion flag", i)
+		} else if string(protocol.Body) != "Hello, world!" {
+			t.Fatalf("feeding by %d: unexpected body: %s", i, quote(protocol.Body))
+		} else if strings.Join(protocol.Extensions, "|") != strings.Join(extensionsExpected, "|") {
+			t.Fatalf("feeding by %d: unexpected extensions: %q", i, protocol.Extensions)
+		}
+	}
+}
+
+func TestChunkExtensionsSkipped(t *testing.T) {
+	protocol := Protocol{}
+	parser := httpparser.NewChunkedBodyParser(protocol.OnBody, 65535)
+	data := []byte("5;foo=bar\r\nhello\r\n0\r\n\r\n")
+
+	done, _, err := parser.Feed(data)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if !done {
+		t.Fatal("no completion flag")
+	} else if string(protocol.Body) != "hello" {
+		t.Fatalf("unexpected body: %s", quote(protocol.Body))
+	}
+}
+
+func TestInvalidChunkExtension(t *testing.T) {
+	for _, data := range []string{
+		"5;=bar\r\nhello\r\n0\r\n\r\n",
+		"5;foo=\r\nhello\r\n0\r\n\r\n",
+		"5;foo=\"bar\r\nhello\r\n0\r\n\r\n",
+		"5 \r\nhello\r\n0\r\n\r\n",
+	} {
+		parser := httpparser.NewChunkedBodyParser(func([]byte) error { return nil }, 65535)
+
+		if _, _, err := parser.Feed([]byte(data)); !errors.Is(err, httpparser.ErrInvalidChunkExtension) {
+			t.Fatalf("%q: expected ErrInvalidChunkExtension, got %v", data, err)
+		}
+	}
+}
+
+func TestChunkExtensionsTooLong(t *testing.T) {
+	protocol := ChunkExtensionsProtocol{}
+	parser, _ := httpparser.NewHTTPRequestParser(&protocol, httpparser.Settings{
+		MaxChunkExtensionsLength: 16,
+	})
+	request := []byte("POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n" +
+		"5;a=b;c=d;e=f;g=h;i=j\r\nhello\r\n0\r\n\r\n")
+
+	if err := parser.Feed(request); !errors.Is(err, httpparser.ErrTooBigChunkExtensions) {
+		t.Fatalf("expected ErrTooBigChunkExtensions, got %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package httpparser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fakefloordiv/snowdrop-http/httpparser"
+)
+
+/*
+	Feeds the chunked body parser with data split into two pieces at the position
+*/
+func feedChunkedSplit(data []byte, split int) (body []byte, done bool, err error) {
+	parser := httpparser.NewChunkedBodyParser(func(piece []byte) error {
+		body = append(body, piece...)
+
+		return nil
+	}, 1<<20)
+
+	for _, piece := range [][]byte{data[:split], data[split:]} {
+		// copy, so the parser doesn't rely on the previous piece
+		if done, _, err = parser.Feed(append([]byte(nil), piece...)); done || err != nil {
+			return body, done, err
+		}
+	}
+
+	return body, done, err
+}
+
+func TestChunkSizeSplit(t *testing.T) {
+	for _, tc := range []struct {
+		data string
+		body string
+	}{
+		{"5\r\nhello\r\n0\r\n\r\n", "hello"},
+		{"A\r\n0123456789\r\n0\r\n\r\n", "0123456789"},
+		{"a\r\n0123456789\r\n0\r\n\r\n", "0123456789"},
+		{"1F\r\n0123456789012345678901234567890\r\n0\r\n\r\n", "0123456789012345678901234567890"},
+		{"0005\r\nhello\r\n000\r\n\r\n", "hello"},
+		{"5;name=value\r\nhello\r\n0\r\n\r\n", "hello"},
+		{"5 ;name\r\nhello\r\n0\r\n\r\n", "hello"},
+		{"5\nhello\n0\n\n", "hello"},
+	} {
+		for split := 0; split <= len(tc.data); split++ {
+			body, done, err := feedChunkedSplit([]byte(tc.data), split)
+
+			switch {
+			case err != nil:
+				t.Fatalf("%q split at %d: unexpected error: %s", tc.data, split, err)
+			case !done:
+				t.Fatalf("%q split at %d: body is not completed", tc.data, split)
+			case string(body) != tc.body:
+				t.Fatalf("%q split at %d: wanted %q, got %q", tc.data, split, tc.body, body)
+			}
+		}
+	}
+}
+
+func TestChunkSizeErrors(t *testing.T) {
+	for _, tc := range []struct {
+		data string
+		err  error
+	}{
+		{"\r\nhello\r\n0\r\n\r\n", httpparser.ErrInvalidChunkSize},
+		{";name\r\nhello\r\n0\r\n\r\n", httpparser.ErrInvalidChunkSize},
+		{" 5\r\nhello\r\n0\r\n\r\n", httpparser.ErrInvalidChunkSize},
+		{"\t5\r\nhello\r\n0\r\n\r\n", httpparser.ErrInvalidChunkSize},
+		{"5g\r\nhello\r\n0\r\n\r\n", httpparser.ErrInvalidChunkSize},
+		{"-5\r\nhello\r\n0\r\n\r\n", httpparser.ErrInvalidChunkSize},
+		{"0x5\r\nhello\r\n0\r\n\r\n", httpparser.ErrInvalidChunkSize},
+		{"5\r\nhello\r\nz\r\n\r\n", httpparser.ErrInvalidChunkSize},
+		// 16 digits, even though the value is small
+		{"0000000000000005\r\nhello\r\n0\r\n\r\n", httpparser.ErrTooBigChunkSize},
+		{"fffffffffffffffff\r\n", httpparser.ErrTooBigChunkSize},
+		{"100001\r\n", httpparser.ErrTooBigChunkSize},
+	} {
+		for split := 0; split <= len(tc.data); split++ {
+			body, _, err := feedChunkedSplit([]byte(tc.data), split)
+
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("%q split at %d: expected %v, got %v", tc.data, split, tc.err, err)
+			} else if tc.err == httpparser.ErrTooBigChunkSize && len(body) > 0 {
+				t.Fatalf("%q split at %d: body must not be pushed", tc.data, split)
+			}
+		}
+	}
+}
+
+func TestChunkSizeNoOverflow(t *testing.T) {
+	maxInt := int(^uint(0) >> 1)
+	settings := httpparser.Settings{MaxChunkLength: maxInt, MaxBodyLength: maxInt}
+	parser, _ := httpparser.NewHTTPRequestParser(&Protocol{}, settings)
+	// without the limit of digits, it would overflow and wrap around to a small size
+	request := "POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n10000000000000005\r\nhello\r\n0\r\n\r\n"
+
+	if err := parser.Feed([]byte(request)); !errors.Is(err, httpparser.ErrTooBigChunkSize) {
+		t.Fatalf("expected ErrTooBigChunkSize, got %v", err)
+	}
+}
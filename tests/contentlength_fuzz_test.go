@@ -0,0 +1,38 @@
+//go:build go1.18
+// +build go1.18
+
+package httpparser
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/fakefloordiv/snowdrop-http/httpparser"
+)
+
+func FuzzParseContentLength(f *testing.F) {
+	for _, seed := range []string{
+		"0", "42", " 42\t", "", "+1", "-1", "1a", "9223372036854775807", "9223372036854775808",
+		"00000000000000000001", "18446744073709551616",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, value string) {
+		length, err := httpparser.ParseContentLength([]byte(value))
+
+		trimmed := strings.Trim(value, " \t")
+		expected, expectedErr := strconv.ParseUint(trimmed, 10, 63)
+		valid := expectedErr == nil && len(trimmed) <= 19
+
+		switch {
+		case valid && err != nil:
+			t.Fatalf("%q: unexpected error: %s", value, err)
+		case !valid && err == nil:
+			t.Fatalf("%q: expected error, got %d", value, length)
+		case valid && uint64(length) != expected:
+			t.Fatalf("%q: wanted %d, got %d", value, expected, length)
+		}
+	})
+}
@@ -0,0 +1,57 @@
+package httpparser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fakefloordiv/snowdrop-http/httpparser"
+)
+
+func TestParseContentLength(t *testing.T) {
+	for _, tc := range []struct {
+		value  string
+		length int
+		err    error
+	}{
+		{"0", 0, nil},
+		{"12345", 12345, nil},
+		{" \t42 ", 42, nil},
+		{"007", 7, nil},
+		{"9223372036854775807", 9223372036854775807, nil},
+		{"", 0, httpparser.ErrEmptyContentLength},
+		{"  ", 0, httpparser.ErrEmptyContentLength},
+		{"+5", 0, httpparser.ErrSignedContentLength},
+		{"-5", 0, httpparser.ErrSignedContentLength},
+		{"9223372036854775808", 0, httpparser.ErrContentLengthOverflow},
+		{"9999999999999999999", 0, httpparser.ErrContentLengthOverflow},
+		{"00000000000000000001", 0, httpparser.ErrContentLengthOverflow},
+		{"18446744073709551621", 0, httpparser.ErrContentLengthOverflow},
+		{"12a", 0, httpparser.ErrInvalidContentLength},
+		{"1 2", 0, httpparser.ErrInvalidContentLength},
+		{"0x10", 0, httpparser.ErrInvalidContentLength},
+	} {
+		length, err := httpparser.ParseContentLength([]byte(tc.value))
+
+		if err != tc.err {
+			t.Fatalf("%q: expected %v, got %v", tc.value, tc.err, err)
+		} else if length != tc.length {
+			t.Fatalf("%q: wanted %d, got %d", tc.value, tc.length, length)
+		} else if err != nil && !errors.Is(err, httpparser.ErrInvalidContentLength) {
+			t.Fatalf("%q: error must wrap ErrInvalidContentLength", tc.value)
+		}
+	}
+}
+
+func TestContentLengthOverflowRejected(t *testing.T) {
+	for _, value := range []string{"", "+5", "18446744073709551621", "99999999999999999999999"} {
+		protocol := Protocol{}
+		parser, _ := httpparser.NewHTTPRequestParser(&protocol, httpparser.Settings{})
+		err := parser.Feed([]byte("POST / HTTP/1.1\r\nContent-Length: " + value + "\r\n\r\nhello"))
+
+		if !errors.Is(err, httpparser.ErrInvalidContentLength) {
+			t.Fatalf("%q: expected ErrInvalidContentLength, got %v", value, err)
+		} else if protocol.Body != nil {
+			t.Fatalf("%q: body must not be pushed", value)
+		}
+	}
+}
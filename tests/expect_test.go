@@ -0,0 +1,100 @@
+package httpparser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fakefloordiv/snowdrop-http/httpparser"
+)
+
+type ExpectContinueProtocol struct {
+	Protocol
+	Proceed       bool
+	Err           error
+	ExpectedTimes int
+}
+
+func (p *ExpectContinueProtocol) OnExpectContinue() (proceed bool, err error) {
+	p.ExpectedTimes++
+
+	return p.Proceed, p.Err
+}
+
+func TestExpectContinueProceed(t *testing.T) {
+	request := []byte("POST / HTTP/1.1\r\nExpect: 100-Continue\r\nContent-Length: 5\r\n\r\nhello")
+
+	for i := 1; i <= len(request); i++ {
+		protocol := ExpectContinueProtocol{Proceed: true}
+		parser, _ := httpparser.NewHTTPRequestParser(&protocol, httpparser.Settings{})
+
+		if err := FeedParser(parser, request, i); err != nil {
+			t.Fatalf("feeding by %d: unexpected error: %s", i, err)
+		} else if protocol.ExpectedTimes != 1 {
+			t.Fatalf("feeding by %d: wanted 1 expectation, got %d", i, protocol.ExpectedTimes)
+		} else if string(protocol.Body) != "hello" {
+			t.Fatalf("feeding by %d: unexpected body: %s", i, quote(protocol.Body))
+		}
+	}
+}
+
+func TestExpectContinueSkipBody(t *testing.T) {
+	for _, request := range []string{
+		"POST / HTTP/1.1\r\nExpect: 100-continue\r\nContent-Length: 5\r\n\r\nhello",
+		"POST / HTTP/1.1\r\nExpect: 100-continue\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n0\r\n\r\n",
+	} {
+		protocol := ExpectContinueProtocol{}
+		parser, _ := httpparser.NewHTTPRequestParser(&protocol, httpparser.Settings{})
+		data := []byte(request + "POST / HTTP/1.1\r\nContent-Length: 2\r\n\r\nok")
+
+		if err := FeedParser(parser, data, 3); err != nil {
+			t.Fatalf("%q: unexpected error: %s", request, err)
+		} else if protocol.CompletedTimes != 2 {
+			t.Fatalf("%q: wanted 2 completions, got %d", request, protocol.CompletedTimes)
+		} else if string(protocol.Body) != "ok" {
+			t.Fatalf("%q: body of first request must be skipped, got %s", request, quote(protocol.Body))
+		}
+	}
+}
+
+func TestExpectContinueRejectAndClose(t *testing.T) {
+	protocol := ExpectContinueProtocol{}
+	parser, _ := httpparser.NewHTTPRequestParser(&protocol, httpparser.Settings{
+		CloseOnRejectedExpectation: true,
+	})
+	// client got the rejection, so it sends the next request instead of the body
+	request := "PUT / HTTP/1.1\r\nExpect: 100-continue\r\nContent-Length: 5\r\n\r\n" +
+		"GET /next HTTP/1.1\r\n\r\n"
+
+	if err := parser.Feed([]byte(request)); err != httpparser.ErrConnectionClosed {
+		t.Fatalf("wanted ErrConnectionClosed, got %v", err)
+	} else if protocol.CompletedTimes != 1 || string(protocol.Method) != "PUT" {
+		t.Fatalf("only rejected request must be completed, got %d completions", protocol.CompletedTimes)
+	} else if len(protocol.Body) != 0 {
+		t.Fatalf("next request must not be taken as a body, got %s", quote(protocol.Body))
+	} else if parser.ShouldKeepAlive() {
+		t.Fatal("connection must be closed after rejected request")
+	}
+}
+
+func TestExpectContinueError(t *testing.T) {
+	expectationFailed := errors.New("expectation failed")
+	protocol := ExpectContinueProtocol{Err: expectationFailed}
+	parser, _ := httpparser.NewHTTPRequestParser(&protocol, httpparser.Settings{})
+	request := "POST / HTTP/1.1\r\nExpect: 100-continue\r\nContent-Length: 5\r\n\r\nhello"
+
+	if err := parser.Feed([]byte(request)); err != expectationFailed {
+		t.Fatalf("expected callback's error, got %v", err)
+	}
+}
+
+func TestExpectContinueWithoutBody(t *testing.T) {
+	protocol := ExpectContinueProtocol{}
+	parser, _ := httpparser.NewHTTPRequestParser(&protocol, httpparser.Settings{})
+	request := "GET / HTTP/1.1\r\nExpect: 100-continue\r\nContent-Length: 0\r\n\r\n"
+
+	if err := parser.Feed([]byte(request)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if protocol.ExpectedTimes != 0 {
+		t.Fatal("callback must not be called for requests without body")
+	}
+}
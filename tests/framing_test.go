@@ -0,0 +1,180 @@
+package httpparser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fakefloordiv/snowdrop-http/httpparser"
+)
+
+func TestConflictingFramingStrict(t *testing.T) {
+	for _, request := range []string{
+		"POST / HTTP/1.1\r\nContent-Length: 5\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n\r\n",
+		"POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\nContent-Length: 5\r\n\r\n0\r\n\r\n",
+	} {
+		parser, _ := httpparser.NewHTTPRequestParser(&Protocol{}, httpparser.Settings{
+			StrictFraming: true,
+		})
+
+		if err := parser.Feed([]byte(request)); !errors.Is(err, httpparser.ErrConflictingFraming) {
+			t.Fatalf("expected ErrConflictingFraming, got %v", err)
+		}
+	}
+}
+
+func TestWhitespaceBeforeColonStrictFraming(t *testing.T) {
+	protocol := Protocol{}
+	parser, _ := httpparser.NewHTTPRequestParser(&protocol, httpparser.Settings{
+		StrictFraming: true,
+	})
+	request := "POST / HTTP/1.1\r\nTransfer-Encoding : chunked\r\nContent-Length: 4\r\n\r\n0\r\n\r\n"
+
+	if err := parser.Feed([]byte(request)); !errors.Is(err, httpparser.ErrWhitespaceBeforeColon) {
+		t.Fatalf("expected ErrWhitespaceBeforeColon, got %v", err)
+	} else if len(protocol.Body) != 0 {
+		t.Fatalf("request must be rejected before the body, got %s", quote(protocol.Body))
+	}
+}
+
+func TestConflictingFramingLenient(t *testing.T) {
+	protocol := Protocol{}
+	parser, _ := httpparser.NewHTTPRequestParser(&protocol, httpparser.Settings{})
+	request := []byte("POST / HTTP/1.1\r\nContent-Length: 3\r\nTransfer-Encoding: chunked\r\n\r\n" +
+		"5\r\nhello\r\n0\r\n\r\n" +
+		"GET /smuggled HTTP/1.1\r\n\r\n")
+
+	if err := FeedParser(parser, request, 5); err != httpparser.ErrConnectionClosed {
+		t.Fatalf("expected ErrConnectionClosed, got %v", err)
+	} else if string(protocol.Body) != "hello" {
+		t.Fatalf("chunked body must win, got %s", quote(protocol.Body))
+	} else if protocol.CompletedTimes != 1 {
+		t.Fatalf("smuggled request must not be parsed, got %d completions", protocol.CompletedTimes)
+	}
+}
+
+func TestDuplicateContentLength(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		parser, _ := httpparser.NewHTTPRequestParser(&Protocol{}, httpparser.Settings{
+			StrictFraming: strict,
+		})
+		request := "POST / HTTP/1.1\r\nContent-Length: 5\r\nContent-Length: 6\r\n\r\nhello!"
+
+		if err := parser.Feed([]byte(request)); !errors.Is(err, httpparser.ErrDuplicateContentLength) {
+			t.Fatalf("strict=%t: expected ErrDuplicateContentLength, got %v", strict, err)
+		}
+
+		parser, _ = httpparser.NewHTTPRequestParser(&Protocol{}, httpparser.Settings{
+			StrictFraming: strict,
+		})
+		request = "POST / HTTP/1.1\r\nContent-Length: 5, 6\r\n\r\nhello!"
+
+		if err := parser.Feed([]byte(request)); !errors.Is(err, httpparser.ErrDuplicateContentLength) {
+			t.Fatalf("strict=%t: expected ErrDuplicateContentLength, got %v", strict, err)
+		}
+	}
+}
+
+func TestIdenticalContentLengths(t *testing.T) {
+	protocol := Protocol{}
+	parser, _ := httpparser.NewHTTPRequestParser(&protocol, httpparser.Settings{
+		StrictFraming: true,
+	})
+	request := "POST / HTTP/1.1\r\nContent-Length: 5 , 5,5\r\n\r\nhello"
+
+	if err := parser.Feed([]byte(request)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if string(protocol.Body) != "hello" {
+		t.Fatalf("unexpected body: %s", quote(protocol.Body))
+	}
+
+	// the same, but in different header lines, is allowed only in lenient mode
+	request = "POST / HTTP/1.1\r\nContent-Length: 5\r\nContent-Length: 5\r\n\r\nhello"
+	protocol = Protocol{}
+	parser, _ = httpparser.NewHTTPRequestParser(&protocol, httpparser.Settings{})
+
+	if err := parser.Feed([]byte(request)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if string(protocol.Body) != "hello" {
+		t.Fatalf("unexpected body: %s", quote(protocol.Body))
+	}
+
+	parser, _ = httpparser.NewHTTPRequestParser(&Protocol{}, httpparser.Settings{
+		StrictFraming: true,
+	})
+
+	if err := parser.Feed([]byte(request)); !errors.Is(err, httpparser.ErrDuplicateContentLength) {
+		t.Fatalf("expected ErrDuplicateContentLength, got %v", err)
+	}
+}
+
+type TransferCodingsProtocol struct {
+	Protocol
+	Codings []string
+}
+
+func (p *TransferCodingsProtocol) OnTransferCoding(coding []byte) error {
+	p.Codings = append(p.Codings, string(coding))
+
+	return nil
+}
+
+func TestTransferEncodingList(t *testing.T) {
+	request := []byte("POST / HTTP/1.1\r\n" +
+		"Transfer-Encoding: gzip\r\n" +
+		"Transfer-Encoding: Deflate , chunked\r\n" +
+		"\r\n5\r\nhello\r\n0\r\n\r\n")
+
+	for i := 1; i <= len(request); i++ {
+		protocol := TransferCodingsProtocol{}
+		parser, _ := httpparser.NewHTTPRequestParser(&protocol, httpparser.Settings{})
+
+		if err := FeedParser(parser, request, i); err != nil {
+			t.Fatalf("feeding by %d: unexpected error: %s", i, err)
+		} else if protocol.CompletedTimes != 1 {
+			t.Fatalf("feeding by %d: no completion flag", i)
+		} else if string(protocol.Body) != "hello" {
+			t.Fatalf("feeding by %d: unexpected body: %s", i, quote(protocol.Body))
+		} else if len(protocol.Codings) != 2 || protocol.Codings[0] != "gzip" || protocol.Codings[1] != "deflate" {
+			t.Fatalf("feeding by %d: unexpected codings: %q", i, protocol.Codings)
+		}
+	}
+}
+
+func TestTransferEncodingChunkedNotFinal(t *testing.T) {
+	for _, encodings := range []string{
+		"gzip",
+		"chunked, gzip",
+		"chunked\r\nTransfer-Encoding: gzip",
+		"chunked, chunked",
+	} {
+		parser, _ := httpparser.NewHTTPRequestParser(&Protocol{}, httpparser.Settings{})
+		request := "POST / HTTP/1.1\r\nTransfer-Encoding: " + encodings + "\r\n\r\n5\r\nhello\r\n0\r\n\r\n"
+
+		if err := parser.Feed([]byte(request)); !errors.Is(err, httpparser.ErrInvalidTransferEncoding) {
+			t.Fatalf("%q: expected ErrInvalidTransferEncoding, got %v", encodings, err)
+		}
+	}
+}
+
+func TestTransferEncodingUnknown(t *testing.T) {
+	parser, _ := httpparser.NewHTTPRequestParser(&Protocol{}, httpparser.Settings{})
+	request := "POST / HTTP/1.1\r\nTransfer-Encoding: br, chunked\r\n\r\n5\r\nhello\r\n0\r\n\r\n"
+
+	if err := parser.Feed([]byte(request)); !errors.Is(err, httpparser.ErrUnsupportedTransferEncoding) {
+		t.Fatalf("expected ErrUnsupportedTransferEncoding, got %v", err)
+	}
+}
+
+func TestResponseTransferEncodingNotChunked(t *testing.T) {
+	protocol := ResponseProtocol{}
+	parser := httpparser.NewHTTPResponseParser(&protocol, httpparser.Settings{})
+	response := "HTTP/1.1 200 OK\r\nTransfer-Encoding: gzip\r\n\r\nhello"
+
+	if err := parser.Feed([]byte(response)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if err = parser.Feed(nil); err != httpparser.ErrConnectionClosed {
+		t.Fatalf("expected ErrConnectionClosed, got %v", err)
+	} else if string(protocol.Body) != "hello" {
+		t.Fatalf("unexpected body: %s", quote(protocol.Body))
+	}
+}
@@ -0,0 +1,92 @@
+package httpparser
+
+import (
+	"testing"
+
+	"github.com/fakefloordiv/snowdrop-http/httpparser"
+)
+
+type knownHeader struct {
+	id    httpparser.HeaderID
+	value string
+}
+
+type KnownHeadersProtocol struct {
+	Protocol
+	Known []knownHeader
+}
+
+func (p *KnownHeadersProtocol) OnKnownHeader(id httpparser.HeaderID, value []byte) error {
+	p.Known = append(p.Known, knownHeader{id, string(value)})
+
+	return nil
+}
+
+type KnownHeadersResponseProtocol struct {
+	ResponseProtocol
+	Known []knownHeader
+}
+
+func (p *KnownHeadersResponseProtocol) OnKnownHeader(id httpparser.HeaderID, value []byte) error {
+	p.Known = append(p.Known, knownHeader{id, string(value)})
+
+	return nil
+}
+
+func TestKnownHeaders(t *testing.T) {
+	request := []byte(
+		"POST / HTTP/1.1\r\nhost: rush.dev\r\nX-Custom: 1\r\nCOOKIE: a=b\r\nContent-Type: text/plain\r\n" +
+			"Authorization: Bearer x\r\nContent-Lengths: 1\r\nte: trailers\r\nContent-Length: 2\r\n\r\nok",
+	)
+	expected := []knownHeader{
+		{httpparser.HeaderHost, "rush.dev"},
+		{httpparser.HeaderCookie, "a=b"},
+		{httpparser.HeaderContentType, "text/plain"},
+		{httpparser.HeaderAuthorization, "Bearer x"},
+		{httpparser.HeaderTE, "trailers"},
+		{httpparser.HeaderContentLength, "2"},
+	}
+
+	for i := 1; i <= len(request); i++ {
+		protocol := KnownHeadersProtocol{}
+		parser, _ := httpparser.NewHTTPRequestParser(&protocol, httpparser.Settings{})
+
+		if err := FeedParser(parser, request, i); err != nil {
+			t.Fatalf("feeding by %d: unexpected error: %s", i, err)
+		} else if len(protocol.Known) != len(expected) {
+			t.Fatalf("feeding by %d: wanted %v, got %v", i, expected, protocol.Known)
+		}
+
+		for j, header := range expected {
+			if protocol.Known[j] != header {
+				t.Fatalf("feeding by %d: wanted %v, got %v", i, expected, protocol.Known)
+			}
+		}
+	}
+}
+
+func TestKnownHeadersResponse(t *testing.T) {
+	protocol := KnownHeadersResponseProtocol{}
+	parser := httpparser.NewHTTPResponseParser(&protocol, httpparser.Settings{})
+
+	if err := parser.Feed([]byte("HTTP/1.1 200 OK\r\nSet-Cookie: a=b\r\nServer: snowdrop\r\nContent-Length: 0\r\n\r\n")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if len(protocol.Known) != 3 || protocol.Known[0].id != httpparser.HeaderSetCookie ||
+		protocol.Known[1].id != httpparser.HeaderServer || protocol.Known[2].id != httpparser.HeaderContentLength {
+		t.Fatalf("unexpected known headers: %v", protocol.Known)
+	}
+}
+
+func TestHeaderIDString(t *testing.T) {
+	for id, name := range map[httpparser.HeaderID]string{
+		httpparser.HeaderUnknown:          "",
+		httpparser.HeaderContentLength:    "Content-Length",
+		httpparser.HeaderTransferEncoding: "Transfer-Encoding",
+		httpparser.HeaderXRequestID:       "X-Request-ID",
+		httpparser.HeaderID(255):          "",
+	} {
+		if id.String() != name {
+			t.Fatalf("wanted %q, got %q", name, id.String())
+		}
+	}
+}
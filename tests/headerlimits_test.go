@@ -0,0 +1,90 @@
+package httpparser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/fakefloordiv/snowdrop-http/httpparser"
+)
+
+type countingProtocol struct {
+	Protocol
+	headers int
+}
+
+func (p *countingProtocol) OnHeader(key, value []byte) error {
+	p.headers++
+
+	return nil
+}
+
+func TestMaxHeadersCount(t *testing.T) {
+	settings := httpparser.Settings{MaxHeadersCount: 3}
+	headers := "A: 1\r\nB: 2\r\nC: 3\r\n"
+
+	for i := 1; i <= 64; i++ {
+		parser, _ := httpparser.NewHTTPRequestParser(&Protocol{}, settings)
+
+		if err := FeedParser(parser, []byte("GET / HTTP/1.1\r\n"+headers+"\r\n"), i); err != nil {
+			t.Fatalf("feeding by %d: unexpected error: %s", i, err)
+		}
+
+		protocol := countingProtocol{}
+		parser, _ = httpparser.NewHTTPRequestParser(&protocol, settings)
+		err := FeedParser(parser, []byte("GET / HTTP/1.1\r\n"+headers+"D: 4\r\n\r\n"), i)
+
+		if !errors.Is(err, httpparser.ErrTooManyHeaders) {
+			t.Fatalf("feeding by %d: expected ErrTooManyHeaders, got %v", i, err)
+		} else if protocol.headers != 3 {
+			t.Fatalf("feeding by %d: OnHeader must not be called for excess header, called %d times", i, protocol.headers)
+		} else if httpparser.ErrorStatusCode(err) != 431 {
+			t.Fatalf("feeding by %d: wanted 431, got %d", i, httpparser.ErrorStatusCode(err))
+		}
+	}
+}
+
+func TestMaxHeaderSectionSize(t *testing.T) {
+	// 3 headers of 6 bytes each, and the final CRLF
+	settings := httpparser.Settings{MaxHeaderSectionSize: 20}
+	headers := "A: 1\r\nB: 2\r\nC: 3\r\n"
+
+	parser, _ := httpparser.NewHTTPRequestParser(&Protocol{}, settings)
+
+	if err := parser.Feed([]byte("GET / HTTP/1.1\r\n" + headers + "\r\n")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	parser, _ = httpparser.NewHTTPRequestParser(&Protocol{}, settings)
+	err := parser.Feed([]byte("GET / HTTP/1.1\r\n" + headers + "D: 4\r\n\r\n"))
+
+	var parseErr *httpparser.ParseError
+
+	if !errors.Is(err, httpparser.ErrHeaderSectionOverflow) {
+		t.Fatalf("expected ErrHeaderSectionOverflow, got %v", err)
+	} else if !errors.As(err, &parseErr) || parseErr.Offset != int64(len("GET / HTTP/1.1\r\n")+20) {
+		t.Fatalf("unexpected error position: %v", err)
+	} else if httpparser.ErrorStatusCode(err) != 431 {
+		t.Fatalf("wanted 431, got %d", httpparser.ErrorStatusCode(err))
+	}
+}
+
+func TestHeaderLimitsPerMessage(t *testing.T) {
+	settings := httpparser.Settings{MaxHeadersCount: 2}
+	request := "GET / HTTP/1.1\r\nA: 1\r\nB: 2\r\n\r\n"
+	parser, _ := httpparser.NewHTTPRequestParser(&Protocol{}, settings)
+
+	if err := parser.Feed([]byte(strings.Repeat(request, 5))); err != nil {
+		t.Fatalf("limits must be applied to every message separately: %s", err)
+	}
+}
+
+func TestMaxHeadersCountTrailers(t *testing.T) {
+	settings := httpparser.Settings{MaxHeadersCount: 1}
+	request := "POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n0\r\nA: 1\r\nB: 2\r\n\r\n"
+	parser, _ := httpparser.NewHTTPRequestParser(&TrailersProtocol{}, settings)
+
+	if err := parser.Feed([]byte(request)); !errors.Is(err, httpparser.ErrTooManyHeaders) {
+		t.Fatalf("expected ErrTooManyHeaders, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package httpparser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fakefloordiv/snowdrop-http/httpparser"
+)
+
+func testMethod(settings httpparser.Settings, method string) (*Protocol, error) {
+	protocol := Protocol{}
+	parser, _ := httpparser.NewHTTPRequestParser(&protocol, settings)
+
+	return &protocol, FeedParser(parser, []byte(method+" / HTTP/1.1\r\n\r\n"), 3)
+}
+
+func TestExtraMethods(t *testing.T) {
+	settings := httpparser.Settings{
+		ExtraMethods: [][]byte{[]byte("PROPFIND"), []byte("PROPPATCH"), []byte("MKCOL"), []byte("PURGE")},
+	}
+
+	for _, method := range []string{"GET", "PROPFIND", "PROPPATCH", "MKCOL", "PURGE"} {
+		if protocol, err := testMethod(settings, method); err != nil {
+			t.Fatalf("%s: unexpected error: %s", method, err)
+		} else if string(protocol.Method) != method {
+			t.Fatalf("%s: got method %s", method, quote(protocol.Method))
+		}
+	}
+
+	for _, method := range []string{"QUERY", "propfind"} {
+		if _, err := testMethod(settings, method); !errors.Is(err, httpparser.ErrInvalidMethod) {
+			t.Fatalf("%s: expected ErrInvalidMethod, got %v", method, err)
+		}
+	}
+}
+
+func TestTokenMethods(t *testing.T) {
+	settings := httpparser.Settings{TokenMethods: true}
+
+	for _, method := range []string{"GET", "QUERY", "PROPFIND", "M-SEARCH", "x_custom!"} {
+		if protocol, err := testMethod(settings, method); err != nil {
+			t.Fatalf("%s: unexpected error: %s", method, err)
+		} else if string(protocol.Method) != method {
+			t.Fatalf("%s: got method %s", method, quote(protocol.Method))
+		}
+	}
+
+	for _, method := range []string{"GE(T", "GET/", "\x01"} {
+		if _, err := testMethod(settings, method); !errors.Is(err, httpparser.ErrInvalidMethod) {
+			t.Fatalf("%q: expected ErrInvalidMethod, got %v", method, err)
+		}
+	}
+}
+
+func TestMaxMethodLength(t *testing.T) {
+	settings := httpparser.Settings{TokenMethods: true, MaxMethodLength: 5}
+
+	if _, err := testMethod(settings, "QUERY"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := testMethod(settings, "SEARCH"); !errors.Is(err, httpparser.ErrInvalidMethod) {
+		t.Fatalf("expected ErrInvalidMethod, got %v", err)
+	}
+
+	// by default, it is long enough for all the extra methods
+	settings = httpparser.Settings{ExtraMethods: [][]byte{[]byte("VERSION-CONTROL")}}
+
+	if _, err := testMethod(settings, "VERSION-CONTROL"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
@@ -0,0 +1,75 @@
+package httpparser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fakefloordiv/snowdrop-http/httpparser"
+)
+
+func TestNormalizePath(t *testing.T) {
+	for _, tc := range []struct {
+		target string
+		path   string
+	}{
+		{"/", "/"},
+		{"/index.html", "/index.html"},
+		{"/a/./b/../c", "/a/c"},
+		{"/a//b///c", "/a/b/c"},
+		{"/a/b/", "/a/b/"},
+		{"/a/b/..", "/a/"},
+		{"/%7Euser/%61bc", "/~user/abc"},
+		{"/a%2Fb/%2e%2E/c", "/c"},
+		{"/a%2fb", "/a%2fb"},
+		{"/%41%20b", "/A%20b"},
+		{"/a/..?x=/../..", "/"},
+		{"http://rush.dev/a/../b", "/b"},
+		{"http://rush.dev", "/"},
+	} {
+		protocol := TargetProtocol{}
+		parser, _ := httpparser.NewHTTPRequestParser(&protocol, httpparser.Settings{NormalizePath: true})
+		request := []byte("GET " + tc.target + " HTTP/1.1\r\n\r\n")
+
+		for i := 1; i <= len(request); i++ {
+			if err := FeedParser(parser, request, i); err != nil {
+				t.Fatalf("%q by %d: unexpected error: %s", tc.target, i, err)
+			} else if string(protocol.URLPath) != tc.path {
+				t.Fatalf("%q by %d: wanted path %q, got %q", tc.target, i, tc.path, protocol.URLPath)
+			} else if string(protocol.Path) != tc.target {
+				t.Fatalf("%q by %d: raw target must be passed to OnPath, got %q", tc.target, i, protocol.Path)
+			}
+		}
+	}
+}
+
+func TestNormalizePathErrors(t *testing.T) {
+	for _, tc := range []struct {
+		target string
+		err    error
+	}{
+		{"/..", httpparser.ErrUnsafePath},
+		{"/a/../..", httpparser.ErrUnsafePath},
+		{"/%2e%2e/etc/passwd", httpparser.ErrUnsafePath},
+		{"/a%00b", httpparser.ErrUnsafePath},
+		{"/a%zz", httpparser.ErrInvalidPath},
+		{"/a%4", httpparser.ErrInvalidPath},
+	} {
+		parser, _ := httpparser.NewHTTPRequestParser(&TargetProtocol{}, httpparser.Settings{NormalizePath: true})
+		err := parser.Feed([]byte("GET " + tc.target + " HTTP/1.1\r\n\r\n"))
+
+		if !errors.Is(err, tc.err) {
+			t.Fatalf("%q: expected %v, got %v", tc.target, tc.err, err)
+		}
+	}
+}
+
+func TestNormalizePathDisabled(t *testing.T) {
+	protocol := TargetProtocol{}
+	parser, _ := httpparser.NewHTTPRequestParser(&protocol, httpparser.Settings{})
+
+	if err := parser.Feed([]byte("GET /a/../%2e%2e//b HTTP/1.1\r\n\r\n")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if string(protocol.URLPath) != "/a/../%2e%2e//b" {
+		t.Fatalf("path must be passed as is, got %q", protocol.URLPath)
+	}
+}
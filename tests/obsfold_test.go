@@ -0,0 +1,86 @@
+package httpparser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fakefloordiv/snowdrop-http/httpparser"
+)
+
+func TestObsFoldUnfold(t *testing.T) {
+	for _, tc := range []struct {
+		request string
+		value   string
+	}{
+		{"GET / HTTP/1.1\r\nSOAPAction: urn:a\r\n b\r\nHost: rush.dev\r\n\r\n", "urn:a b"},
+		{"GET / HTTP/1.1\r\nSOAPAction: urn:a  \r\n\t  b\r\n   c\r\nHost: rush.dev\r\n\r\n", "urn:a b c"},
+		{"GET / HTTP/1.1\nSOAPAction: urn:a\n b\nHost: rush.dev\n\n", "urn:a b"},
+		{"GET / HTTP/1.1\r\nSOAPAction: urn:a\r\n   \r\nHost: rush.dev\r\n\r\n", "urn:a"},
+		{"GET / HTTP/1.1\r\nSOAPAction: urn:a\r\n \r\n b\r\nHost: rush.dev\r\n\r\n", "urn:a b"},
+		{"GET / HTTP/1.1\r\nSOAPAction:\r\n urn:a\r\nHost: rush.dev\r\n\r\n", "urn:a"},
+		{"GET / HTTP/1.1\r\nSOAPAction: \r\n\turn:a\r\n b\r\nHost: rush.dev\r\n\r\n", "urn:a b"},
+	} {
+		for i := 1; i <= len(tc.request); i++ {
+			var headers int
+
+			protocol := httpparser.NewRequestProtocol(func(request *httpparser.Request) error {
+				headers = request.Header.Len()
+				value, _ := request.Header.Get("SOAPAction")
+
+				if string(value) != tc.value {
+					t.Fatalf("%q by %d: wanted %q, got %q", tc.request, i, tc.value, value)
+				} else if host, _ := request.Header.Get("Host"); string(host) != "rush.dev" {
+					t.Fatalf("%q by %d: unexpected host: %q", tc.request, i, host)
+				}
+
+				return nil
+			})
+			settings := httpparser.Settings{Strictness: httpparser.UnfoldObsFold}
+			parser, _ := httpparser.NewHTTPRequestParser(protocol, settings)
+
+			if err := FeedParser(parser, []byte(tc.request), i); err != nil {
+				t.Fatalf("%q by %d: unexpected error: %s", tc.request, i, err)
+			} else if headers != 2 {
+				t.Fatalf("%q by %d: folded header must be passed once, got %d headers", tc.request, i, headers)
+			}
+		}
+	}
+}
+
+func TestObsFoldRejected(t *testing.T) {
+	for _, request := range []string{
+		"GET / HTTP/1.1\r\nSOAPAction: urn:a\r\n b\r\n\r\n",
+		"GET / HTTP/1.1\r\nSOAPAction: urn:a\r\n\tb\r\n\r\n",
+		// there is no header to continue
+		"GET / HTTP/1.1\r\n Host: rush.dev\r\n\r\n",
+	} {
+		for _, strictness := range []httpparser.Strictness{0, httpparser.Strict} {
+			protocol := countingProtocol{}
+			parser, _ := httpparser.NewHTTPRequestParser(&protocol, httpparser.Settings{Strictness: strictness})
+			err := parser.Feed([]byte(request))
+
+			if !errors.Is(err, httpparser.ErrObsFold) {
+				t.Fatalf("%q: expected ErrObsFold, got %v", request, err)
+			} else if protocol.headers != 0 {
+				t.Fatalf("%q: folded header must not be passed to the callback", request)
+			}
+		}
+	}
+
+	settings := httpparser.Settings{Strictness: httpparser.UnfoldObsFold}
+	parser, _ := httpparser.NewHTTPRequestParser(&Protocol{}, settings)
+
+	if err := parser.Feed([]byte("GET / HTTP/1.1\r\n Host: rush.dev\r\n\r\n")); !errors.Is(err, httpparser.ErrObsFold) {
+		t.Fatalf("first header line must not begin with a whitespace, got %v", err)
+	}
+}
+
+func TestObsFoldOverflow(t *testing.T) {
+	settings := httpparser.Settings{Strictness: httpparser.UnfoldObsFold, MaxHeaderLineLength: 16}
+	parser, _ := httpparser.NewHTTPRequestParser(&Protocol{}, settings)
+	err := parser.Feed([]byte("GET / HTTP/1.1\r\nX-Key: aaaa\r\n bbbb\r\n cccc\r\n\r\n"))
+
+	if !errors.Is(err, httpparser.ErrHeaderOverflow) {
+		t.Fatalf("unfolded header must be limited as a single line, got %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package httpparser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/fakefloordiv/snowdrop-http/httpparser"
+)
+
+func TestParseErrorPosition(t *testing.T) {
+	firstRequest := "GET / HTTP/1.1\r\nHost: rush.dev\r\n\r\n"
+
+	for _, tc := range []struct {
+		request string
+		err     error
+		state   string
+		// offset within the second request
+		offset int
+	}{
+		{"GET /\x01 HTTP/1.1\r\n\r\n", httpparser.ErrInvalidPath, "path", 5},
+		{"GET / HTTP/1.1\r\nHost\x7f: rush.dev\r\n\r\n", httpparser.ErrInvalidHeader, "headers", 20},
+		{"POST / HTTP/1.1\r\nContent-Length: x\r\n\r\n", httpparser.ErrInvalidContentLength, "headers", 36},
+		{"POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhelloX", httpparser.ErrInvalidChunkSplitter, "body/chunkBodyEnd", 55},
+		{"POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\nx\r\n", httpparser.ErrInvalidChunkSize, "body/chunkLength", 47},
+		{"POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n5;=\r\n", httpparser.ErrInvalidChunkExtension, "body/chunkExtensionName", 49},
+		{"POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n0\r\nX\x01: y\r\n\r\n", httpparser.ErrInvalidHeader, "body/lastChunk", 51},
+		{"POST / HTTP/1.1\r\nTransfer-Encoding: gzip\r\n\r\n", httpparser.ErrInvalidTransferEncoding, "headers", 43},
+	} {
+		for _, chunkSize := range []int{1, 3, 1024} {
+			parser, _ := httpparser.NewHTTPRequestParser(&Protocol{}, httpparser.Settings{})
+			data := []byte(firstRequest + tc.request)
+			err := FeedParser(parser, data, chunkSize)
+
+			var parseErr *httpparser.ParseError
+
+			switch {
+			case !errors.As(err, &parseErr):
+				t.Fatalf("%q by %d: expected ParseError, got %v", tc.request, chunkSize, err)
+			case !errors.Is(err, tc.err):
+				t.Fatalf("%q by %d: expected %v, got %v", tc.request, chunkSize, tc.err, err)
+			case parseErr.State != tc.state:
+				t.Fatalf("%q by %d: wanted state %s, got %s", tc.request, chunkSize, tc.state, parseErr.State)
+			case parseErr.Offset != int64(len(firstRequest)+tc.offset):
+				t.Fatalf("%q by %d: wanted offset %d, got %d",
+					tc.request, chunkSize, len(firstRequest)+tc.offset, parseErr.Offset)
+			case parseErr.Byte != data[parseErr.Offset]:
+				t.Fatalf("%q by %d: wrong offending byte %q", tc.request, chunkSize, parseErr.Byte)
+			case parseErr.MessageIndex != 1:
+				t.Fatalf("%q by %d: wanted message index 1, got %d", tc.request, chunkSize, parseErr.MessageIndex)
+			}
+		}
+	}
+}
+
+func TestParseErrorResponse(t *testing.T) {
+	parser := httpparser.NewHTTPResponseParser(&ResponseProtocol{}, httpparser.Settings{})
+	err := parser.Feed([]byte("HTTP/1.1 2x0 OK\r\n\r\n"))
+
+	var parseErr *httpparser.ParseError
+
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected ParseError, got %v", err)
+	} else if parseErr.State != "statusCode" || parseErr.Offset != 10 || parseErr.Byte != 'x' {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestParseErrorChunkedParser(t *testing.T) {
+	parser := httpparser.NewChunkedBodyParser((&Protocol{}).OnBody, 65535)
+	_, _, err := parser.Feed([]byte("5\r\nhelloX"))
+
+	var parseErr *httpparser.ParseError
+
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected ParseError, got %v", err)
+	} else if !errors.Is(err, httpparser.ErrInvalidChunkSplitter) {
+		t.Fatalf("expected ErrInvalidChunkSplitter, got %v", err)
+	} else if parseErr.State != "chunkBodyEnd" || parseErr.Offset != 8 || parseErr.Byte != 'X' {
+		t.Fatalf("unexpected error: %s", err)
+	} else if !strings.Contains(err.Error(), "ErrInvalidChunkSplitter") {
+		t.Fatalf("error message must contain the sentinel one: %s", err)
+	}
+}
+
+type failingProtocol struct {
+	Protocol
+	err error
+}
+
+func (p *failingProtocol) OnHeader(key, value []byte) error {
+	return p.err
+}
+
+func TestParseErrorCallbackNotWrapped(t *testing.T) {
+	callbackErr := errors.New("callback error")
+	parser, _ := httpparser.NewHTTPRequestParser(&failingProtocol{err: callbackErr}, httpparser.Settings{})
+
+	if err := parser.Feed([]byte("GET / HTTP/1.1\r\nHost: rush.dev\r\n\r\n")); err != callbackErr {
+		t.Fatalf("expected callback's error as is, got %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package httpparser
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -195,7 +196,7 @@ func testInvalidGETRequest(t *testing.T, request []byte, errorWanted error) {
 	parser, _ := httpparser.NewHTTPRequestParser(&protocol, httpparser.Settings{})
 	err := FeedParser(parser, request, 5)
 
-	if err != nil && err != errorWanted {
+	if err != nil && !errors.Is(err, errorWanted) {
 		t.Errorf(`expected "%s" error, got "%s" instead`, errorWanted, err)
 	} else if err == nil && protocol.CompletedTimes != 1 {
 		t.Error("parser didn't return an error and didn't mark request as completed")
@@ -229,7 +230,7 @@ func TestInvalidPOSTRequestExtraBody(t *testing.T) {
 		t.Error("expected error, but no error was returned")
 		return
 	}
-	if err != httpparser.ErrInvalidMethod {
+	if !errors.Is(err, httpparser.ErrInvalidMethod) {
 		/*
 			As we have a stream-based parser, we expect that extra-body always mean a new request
 			So that's why we expect here InvalidMethod error: " Extra" is really invalid method
@@ -479,3 +480,25 @@ func TestConnectionClose(t *testing.T) {
 		t.Error("expected ErrConnectionClosed error, got", err.Error())
 	}
 }
+
+func TestShouldKeepAlive(t *testing.T) {
+	for _, tc := range []struct {
+		request   string
+		keepAlive bool
+	}{
+		{"GET / HTTP/1.1\r\nHost: rush.dev\r\n\r\n", true},
+		{"GET / HTTP/1.1\r\nConnection: keep-alive, Close\r\nContent-Length: 0\r\n\r\n", false},
+		{"GET / HTTP/1.1\r\nConnection: upgrade\r\nConnection: close\r\nContent-Length: 0\r\n\r\n", false},
+		{"GET / HTTP/1.0\r\nHost: rush.dev\r\n\r\n", false},
+		{"GET / HTTP/1.0\r\nConnection: Keep-Alive\r\n\r\n", true},
+		{"POST / HTTP/1.1\r\nContent-Length: 3\r\nTransfer-Encoding: chunked\r\n\r\n", false},
+	} {
+		parser, _ := httpparser.NewHTTPRequestParser(&Protocol{}, httpparser.Settings{})
+
+		if err := parser.Feed([]byte(tc.request)); err != nil {
+			t.Fatalf("%q: unexpected error: %s", tc.request, err)
+		} else if parser.ShouldKeepAlive() != tc.keepAlive {
+			t.Fatalf("%q: wanted keep-alive=%t", tc.request, tc.keepAlive)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package httpparser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fakefloordiv/snowdrop-http/httpparser"
+)
+
+// PauseProtocol pauses the parser every time callback from PauseOn is called
+type PauseProtocol struct {
+	Protocol
+	PauseOn string
+	Events  []string
+}
+
+func (p *PauseProtocol) event(name string) error {
+	// body may be split into pieces in different ways
+	if name != "body" || len(p.Events) == 0 || p.Events[len(p.Events)-1] != "body" {
+		p.Events = append(p.Events, name)
+	}
+
+	if name == p.PauseOn {
+		return httpparser.ErrPause
+	}
+
+	return nil
+}
+
+func (p *PauseProtocol) OnMessageBegin() error {
+	return p.event("begin")
+}
+
+func (p *PauseProtocol) OnMethod(method []byte) error {
+	_ = p.Protocol.OnMethod(method)
+
+	return p.event("method")
+}
+
+func (p *PauseProtocol) OnPath(path []byte) error {
+	_ = p.Protocol.OnPath(path)
+
+	return p.event("path")
+}
+
+func (p *PauseProtocol) OnProtocol(proto []byte) error {
+	_ = p.Protocol.OnProtocol(proto)
+
+	return p.event("protocol")
+}
+
+func (p *PauseProtocol) OnHeadersBegin() error {
+	_ = p.Protocol.OnHeadersBegin()
+
+	return p.event("headersBegin")
+}
+
+func (p *PauseProtocol) OnHeader(key, value []byte) error {
+	return p.event("header " + string(key))
+}
+
+func (p *PauseProtocol) OnHeadersComplete() error {
+	return p.event("headersComplete")
+}
+
+func (p *PauseProtocol) OnBody(chunk []byte) error {
+	_ = p.Protocol.OnBody(chunk)
+
+	return p.event("body")
+}
+
+func (p *PauseProtocol) OnTrailer(key, value []byte) error {
+	return p.event("trailer " + string(key))
+}
+
+func (p *PauseProtocol) OnMessageComplete() error {
+	_ = p.Protocol.OnMessageComplete()
+
+	return p.event("complete")
+}
+
+/*
+	Feeds the parser and resumes it every time it's paused. Checks that Consumed is
+	always in bounds of the data
+*/
+func feedResuming(t *testing.T, parser httpparser.HTTPRequestsParser, data []byte, chunkSize int) (pauses int) {
+	for i := 0; i < len(data); i += chunkSize {
+		end := i + chunkSize
+
+		if end > len(data) {
+			end = len(data)
+		}
+
+		left := end - i
+		err := parser.Feed(data[i:end])
+
+		for {
+			pause, ok := err.(httpparser.Pause)
+
+			if !ok {
+				break
+			}
+
+			if pause.Consumed < 0 || pause.Consumed > left {
+				t.Fatalf("consumed %d bytes of %d", pause.Consumed, left)
+			}
+
+			left -= pause.Consumed
+			pauses++
+			err = parser.Resume()
+		}
+
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	return pauses
+}
+
+func TestPauseOnEveryCallback(t *testing.T) {
+	request := []byte("POST /hello HTTP/1.1\r\nHost: rush.dev\r\nContent-Length: 5\r\n\r\nhello" +
+		"POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n6\r\n world\r\n0\r\nTrailer: yes\r\n\r\n" +
+		"GET / HTTP/1.1\r\n\r\n")
+
+	reference := PauseProtocol{}
+	parser, _ := httpparser.NewHTTPRequestParser(&reference, httpparser.Settings{})
+
+	if err := parser.Feed(request); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for _, pauseOn := range []string{
+		"begin", "method", "path", "protocol", "headersBegin", "header Host", "header Content-Length",
+		"headersComplete", "body", "trailer Trailer", "complete",
+	} {
+		for _, chunkSize := range []int{1, 3, 7, len(request)} {
+			protocol := PauseProtocol{PauseOn: pauseOn}
+			parser, _ := httpparser.NewHTTPRequestParser(&protocol, httpparser.Settings{})
+
+			if pauses := feedResuming(t, parser, request, chunkSize); pauses == 0 {
+				t.Fatalf("%s by %d: parser has never been paused", pauseOn, chunkSize)
+			}
+
+			if strings.Join(protocol.Events, "|") != strings.Join(reference.Events, "|") {
+				t.Fatalf("%s by %d: events mismatch:\nwanted %q\ngot    %q",
+					pauseOn, chunkSize, reference.Events, protocol.Events)
+			} else if string(protocol.Body) != "hellohello world" {
+				t.Fatalf("%s by %d: unexpected body: %s", pauseOn, chunkSize, quote(protocol.Body))
+			}
+		}
+	}
+}
+
+func TestPauseConsumed(t *testing.T) {
+	protocol := PauseProtocol{PauseOn: "header Host"}
+	parser, _ := httpparser.NewHTTPRequestParser(&protocol, httpparser.Settings{})
+	request := "GET / HTTP/1.1\r\nHost: rush.dev\r\nAccept: */*\r\n\r\n"
+	err := parser.Feed([]byte(request))
+
+	// header is reported when the first character of the next line is met
+	if pause, ok := err.(httpparser.Pause); !ok {
+		t.Fatalf("wanted Pause, got %v", err)
+	} else if pause.Consumed != len("GET / HTTP/1.1\r\nHost: rush.dev\r\n") {
+		t.Fatalf("unexpected amount of consumed bytes: %d", pause.Consumed)
+	} else if protocol.CompletedTimes != 0 {
+		t.Fatal("parser must not continue after pause")
+	}
+
+	if err = parser.Feed([]byte("GET")); err != httpparser.ErrNotResumed {
+		t.Fatalf("expected ErrNotResumed, got %v", err)
+	}
+
+	if err = parser.Resume(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if protocol.CompletedTimes != 1 {
+		t.Fatal("no completion flag after resume")
+	}
+}
+
+func TestPauseHeaderSectionSize(t *testing.T) {
+	headers := strings.Repeat("X: a\r\n", 10) + "\r\n"
+	request := []byte("GET / HTTP/1.1\r\n" + headers)
+
+	for _, chunkSize := range []int{1, 3, len(request)} {
+		protocol := PauseProtocol{PauseOn: "header X"}
+		settings := httpparser.Settings{MaxHeaderSectionSize: len(headers)}
+		parser, _ := httpparser.NewHTTPRequestParser(&protocol, settings)
+
+		// pauses must not count any byte twice, so the section fits the limit exactly
+		if pauses := feedResuming(t, parser, request, chunkSize); pauses != 10 {
+			t.Fatalf("by %d: wanted 10 pauses, got %d", chunkSize, pauses)
+		} else if protocol.CompletedTimes != 1 {
+			t.Fatalf("by %d: no completion flag", chunkSize)
+		}
+	}
+}
+
+// ChainPauseProtocol also implements the optional callbacks that follow the basic ones
+type ChainPauseProtocol struct {
+	PauseProtocol
+}
+
+func (p *ChainPauseProtocol) OnURLPath(path []byte) error {
+	return p.event("urlPath")
+}
+
+func (p *ChainPauseProtocol) OnQueryString(query []byte) error {
+	return p.event("query")
+}
+
+func (p *ChainPauseProtocol) OnQueryParam(key, value []byte) error {
+	return p.event("param " + string(key))
+}
+
+func (p *ChainPauseProtocol) OnFragment(fragment []byte) error {
+	return p.event("fragment")
+}
+
+func (p *ChainPauseProtocol) OnAbsoluteTarget(scheme, host, port []byte) error {
+	return p.event("absolute")
+}
+
+func (p *ChainPauseProtocol) OnKnownHeader(id httpparser.HeaderID, value []byte) error {
+	return p.event("known " + id.String())
+}
+
+func (p *ChainPauseProtocol) OnTransferCoding(coding []byte) error {
+	return p.event("coding " + string(coding))
+}
+
+func TestPauseStopsCallbackChain(t *testing.T) {
+	request := []byte("GET /a?x=1&y=2#f HTTP/1.1\r\nHost: rush.dev\r\n\r\n" +
+		"POST http://rush.dev/?z=3 HTTP/1.1\r\nTransfer-Encoding: gzip, deflate, chunked\r\n\r\n0\r\n\r\n" +
+		"GET / HTTP/1.1\r\n\r\n")
+
+	reference := ChainPauseProtocol{}
+	parser, _ := httpparser.NewHTTPRequestParser(&reference, httpparser.Settings{})
+
+	if err := parser.Feed(request); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for _, pauseOn := range []string{
+		"path", "absolute", "urlPath", "query", "param x", "param y", "fragment", "protocol",
+		"header Host", "known Host", "coding gzip", "headersComplete", "complete",
+	} {
+		protocol := ChainPauseProtocol{PauseProtocol{PauseOn: pauseOn}}
+		parser, _ := httpparser.NewHTTPRequestParser(&protocol, httpparser.Settings{})
+		err := parser.Feed(request)
+		pauses := 0
+
+		// every pause must happen right after the pausing callback, nothing is called in between
+		for i, event := range reference.Events {
+			if event != pauseOn {
+				continue
+			}
+
+			if _, ok := err.(httpparser.Pause); !ok {
+				t.Fatalf("%s: wanted Pause, got %v", pauseOn, err)
+			}
+
+			if strings.Join(protocol.Events, "|") != strings.Join(reference.Events[:i+1], "|") {
+				t.Fatalf("%s: callbacks are called after pause:\nwanted %q\ngot    %q",
+					pauseOn, reference.Events[:i+1], protocol.Events)
+			}
+
+			pauses++
+			err = parser.Resume()
+		}
+
+		if pauses == 0 {
+			t.Fatalf("%s: callback has never been called", pauseOn)
+		} else if err != nil {
+			t.Fatalf("%s: unexpected error: %s", pauseOn, err)
+		} else if strings.Join(protocol.Events, "|") != strings.Join(reference.Events, "|") {
+			t.Fatalf("%s: events mismatch:\nwanted %q\ngot    %q", pauseOn, reference.Events, protocol.Events)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package httpparser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fakefloordiv/snowdrop-http/httpparser"
+)
+
+type QueryProtocol struct {
+	Protocol
+	Params [][2]string
+}
+
+func (p *QueryProtocol) OnQueryParam(key, value []byte) error {
+	p.Params = append(p.Params, [2]string{string(key), string(value)})
+
+	return nil
+}
+
+func TestQueryParams(t *testing.T) {
+	for _, tc := range []struct {
+		target string
+		params [][2]string
+	}{
+		{"/", nil},
+		{"/?", nil},
+		{"/?a=1", [][2]string{{"a", "1"}}},
+		{"/?a=1&b=2", [][2]string{{"a", "1"}, {"b", "2"}}},
+		{"/?a&&b=", [][2]string{{"a", ""}, {"b", ""}}},
+		{"/?a=1=2", [][2]string{{"a", "1=2"}}},
+		{"/?hello+world=%D0%BF%D1%80%D0%B8%D0%B2%D0%B5%D1%82", [][2]string{{"hello world", "привет"}}},
+		{"/?a%26b=c%3Dd%2B", [][2]string{{"a&b", "c=d+"}}},
+		{"/?=value", [][2]string{{"", "value"}}},
+		{"/?a=1#b=2", [][2]string{{"a", "1"}}},
+		{"http://rush.dev/?a=1", [][2]string{{"a", "1"}}},
+	} {
+		for i := 1; i <= len(tc.target)+len("GET  HTTP/1.1\r\n\r\n"); i++ {
+			protocol := QueryProtocol{}
+			parser, _ := httpparser.NewHTTPRequestParser(&protocol, httpparser.Settings{})
+			request := []byte("GET " + tc.target + " HTTP/1.1\r\n\r\n")
+
+			if err := FeedParser(parser, request, i); err != nil {
+				t.Fatalf("%q by %d: unexpected error: %s", tc.target, i, err)
+			} else if len(protocol.Params) != len(tc.params) {
+				t.Fatalf("%q by %d: wanted %v, got %v", tc.target, i, tc.params, protocol.Params)
+			}
+
+			for j, param := range tc.params {
+				if protocol.Params[j] != param {
+					t.Fatalf("%q by %d: wanted %v, got %v", tc.target, i, tc.params, protocol.Params)
+				}
+			}
+		}
+	}
+}
+
+func TestQueryParamsErrors(t *testing.T) {
+	settings := httpparser.Settings{MaxQueryParams: 2, MaxQueryParamLength: 8}
+
+	for _, tc := range []struct {
+		target string
+		err    error
+	}{
+		{"/?a=%zz", httpparser.ErrInvalidQuery},
+		{"/?a=%4", httpparser.ErrInvalidQuery},
+		{"/?a=1&b=2&c=3", httpparser.ErrQueryOverflow},
+		{"/?key=value", httpparser.ErrQueryOverflow},
+	} {
+		parser, _ := httpparser.NewHTTPRequestParser(&QueryProtocol{}, settings)
+		err := parser.Feed([]byte("GET " + tc.target + " HTTP/1.1\r\n\r\n"))
+
+		var parseErr *httpparser.ParseError
+
+		if !errors.Is(err, tc.err) {
+			t.Fatalf("%q: expected %v, got %v", tc.target, tc.err, err)
+		} else if !errors.As(err, &parseErr) || parseErr.State != "path" {
+			t.Fatalf("%q: expected ParseError in path state, got %v", tc.target, err)
+		}
+	}
+}
+
+func TestQueryParamsNoAllocs(t *testing.T) {
+	protocol := &allocFreeQueryProtocol{}
+	parser, _ := httpparser.NewHTTPRequestParser(protocol, httpparser.Settings{})
+	request := []byte("GET /search?q=hello+world&lang=%65n&page=2 HTTP/1.1\r\n\r\n")
+
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := parser.Feed(request); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	if allocs != 0 {
+		t.Fatalf("expected no allocations, got %v", allocs)
+	} else if protocol.params != 3*101 {
+		t.Fatalf("unexpected amount of parameters: %d", protocol.params)
+	}
+}
+
+type allocFreeQueryProtocol struct {
+	Protocol
+	params int
+}
+
+func (p *allocFreeQueryProtocol) OnMessageBegin() error      { return nil }
+func (p *allocFreeQueryProtocol) OnMethod([]byte) error      { return nil }
+func (p *allocFreeQueryProtocol) OnPath([]byte) error        { return nil }
+func (p *allocFreeQueryProtocol) OnProtocol([]byte) error    { return nil }
+func (p *allocFreeQueryProtocol) OnHeadersBegin() error      { return nil }
+func (p *allocFreeQueryProtocol) OnHeader(_, _ []byte) error { return nil }
+func (p *allocFreeQueryProtocol) OnHeadersComplete() error   { return nil }
+func (p *allocFreeQueryProtocol) OnBody([]byte) error        { return nil }
+func (p *allocFreeQueryProtocol) OnMessageComplete() error   { return nil }
+
+func (p *allocFreeQueryProtocol) OnQueryParam(key, value []byte) error {
+	p.params++
+
+	return nil
+}
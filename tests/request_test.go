@@ -0,0 +1,100 @@
+package httpparser
+
+import (
+	"testing"
+
+	"github.com/fakefloordiv/snowdrop-http/httpparser"
+)
+
+func TestRequestProtocol(t *testing.T) {
+	data := []byte(
+		"POST /upload HTTP/1.1\r\nHost: rush.dev\r\nAccept: text/html\r\naccept: */*\r\n" +
+			"Transfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n6\r\n world\r\n0\r\nChecksum: 42\r\n\r\n" +
+			"GET / HTTP/1.1\r\n\r\n",
+	)
+
+	for i := 1; i <= len(data); i++ {
+		var requests []string
+
+		protocol := httpparser.NewRequestProtocol(func(request *httpparser.Request) error {
+			requests = append(requests, string(request.Method())+" "+string(request.Path()))
+
+			if len(requests) > 1 {
+				if request.Header.Len() != 0 || len(request.Body()) != 0 {
+					t.Fatalf("feeding by %d: request is not reset", i)
+				}
+
+				return nil
+			}
+
+			accept := request.Header.Values("ACCEPT")
+
+			switch host, found := request.Header.Get("host"); {
+			case !found || string(host) != "rush.dev":
+				t.Fatalf("feeding by %d: unexpected host: %q", i, host)
+			case len(accept) != 2 || string(accept[0]) != "text/html" || string(accept[1]) != "*/*":
+				t.Fatalf("feeding by %d: unexpected accept: %q", i, accept)
+			case string(request.Protocol()) != "HTTP/1.1":
+				t.Fatalf("feeding by %d: unexpected protocol: %q", i, request.Protocol())
+			case string(request.Body()) != "hello world":
+				t.Fatalf("feeding by %d: unexpected body: %q", i, request.Body())
+			}
+
+			if checksum, _ := request.Trailer.Get("checksum"); string(checksum) != "42" {
+				t.Fatalf("feeding by %d: unexpected trailer: %q", i, checksum)
+			}
+
+			if _, found := request.Header.Get("Hos"); found {
+				t.Fatalf("feeding by %d: header must not be found by prefix", i)
+			}
+
+			return nil
+		})
+		parser, _ := httpparser.NewHTTPRequestParser(protocol, httpparser.Settings{})
+
+		if err := FeedParser(parser, data, i); err != nil {
+			t.Fatalf("feeding by %d: unexpected error: %s", i, err)
+		} else if len(requests) != 2 || requests[0] != "POST /upload" || requests[1] != "GET /" {
+			t.Fatalf("feeding by %d: unexpected requests: %q", i, requests)
+		}
+	}
+}
+
+func TestRequestHeaderEach(t *testing.T) {
+	var header httpparser.Header
+
+	header.Add([]byte("A"), []byte("1"))
+	header.Add([]byte("B"), []byte("2"))
+	header.Add([]byte("C"), []byte("3"))
+
+	var keys string
+
+	header.Each(func(key, value []byte) bool {
+		keys += string(key)
+
+		return string(value) != "2"
+	})
+
+	if keys != "AB" {
+		t.Fatalf("unexpected iteration: %s", keys)
+	}
+
+	header.Reset()
+
+	if _, found := header.Get("a"); found || header.Len() != 0 {
+		t.Fatal("header is not reset")
+	}
+}
+
+func TestRequestReuseNoAllocs(t *testing.T) {
+	protocol := httpparser.NewRequestProtocol(nil)
+	parser, _ := httpparser.NewHTTPRequestParser(protocol, httpparser.Settings{})
+	request := []byte("POST / HTTP/1.1\r\nHost: rush.dev\r\nContent-Length: 5\r\n\r\nhello")
+
+	// let the buffers grow
+	_ = parser.Feed(request)
+
+	if allocs := testing.AllocsPerRun(100, func() { _ = parser.Feed(request) }); allocs != 0 {
+		t.Fatalf("expected no allocations, got %v", allocs)
+	}
+}
@@ -0,0 +1,245 @@
+package httpparser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/fakefloordiv/snowdrop-http/httpparser"
+)
+
+type ResponseProtocol struct {
+	Protocol       []byte
+	StatusCode     int
+	Reason         []byte
+	Headers        map[string][]byte
+	Body           []byte
+	CompletedTimes int
+}
+
+func (p *ResponseProtocol) OnProtocol(proto []byte) error {
+	p.Protocol = append(p.Protocol[:0], proto...)
+
+	return nil
+}
+
+func (p *ResponseProtocol) OnStatusCode(code int) error {
+	p.StatusCode = code
+
+	return nil
+}
+
+func (p *ResponseProtocol) OnReason(reason []byte) error {
+	p.Reason = append(p.Reason[:0], reason...)
+
+	return nil
+}
+
+func (p *ResponseProtocol) OnHeader(key, value []byte) error {
+	if p.Headers == nil {
+		p.Headers = make(map[string][]byte)
+	}
+
+	p.Headers[string(key)] = append([]byte(nil), value...)
+
+	return nil
+}
+
+func (p *ResponseProtocol) OnBody(chunk []byte) error {
+	p.Body = append(p.Body, chunk...)
+
+	return nil
+}
+
+func (p *ResponseProtocol) OnMessageComplete() error {
+	p.CompletedTimes++
+
+	return nil
+}
+
+func testResponse(t *testing.T, response string, chunkSize int, wantCode int, wantReason, wantBody string) {
+	protocol := ResponseProtocol{}
+	parser := httpparser.NewHTTPResponseParser(&protocol, httpparser.Settings{})
+
+	if err := FeedParser(parser, []byte(response), chunkSize); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	switch {
+	case protocol.CompletedTimes != 1:
+		t.Fatalf("wanted 1 completion, got %d", protocol.CompletedTimes)
+	case string(protocol.Protocol) != "HTTP/1.1":
+		t.Fatalf("wanted protocol HTTP/1.1, got %s", quote(protocol.Protocol))
+	case protocol.StatusCode != wantCode:
+		t.Fatalf("wanted status code %d, got %d", wantCode, protocol.StatusCode)
+	case string(protocol.Reason) != wantReason:
+		t.Fatalf("wanted reason %q, got %s", wantReason, quote(protocol.Reason))
+	case string(protocol.Body) != wantBody:
+		t.Fatalf("wanted body %q, got %s", wantBody, quote(protocol.Body))
+	}
+}
+
+func TestResponseContentLength(t *testing.T) {
+	response := "HTTP/1.1 200 OK\r\nServer: indigo\r\nContent-Length: 13\r\n\r\nHello, world!"
+
+	for _, chunkSize := range []int{1, 2, 5, len(response)} {
+		testResponse(t, response, chunkSize, 200, "OK", "Hello, world!")
+	}
+}
+
+func TestResponseChunked(t *testing.T) {
+	response := "HTTP/1.1 201 Created\r\nTransfer-Encoding: chunked\r\n\r\n" +
+		"d\r\nHello, world!\r\n1a\r\nBut what's wrong with you?\r\n0\r\n\r\n"
+
+	for _, chunkSize := range []int{1, 2, 5, len(response)} {
+		testResponse(t, response, chunkSize, 201, "Created", "Hello, world!But what's wrong with you?")
+	}
+}
+
+func TestResponseEmptyReason(t *testing.T) {
+	testResponse(t, "HTTP/1.1 200\r\nContent-Length: 0\r\n\r\n", 5, 200, "", "")
+	testResponse(t, "HTTP/1.1 200 \r\nContent-Length: 0\r\n\r\n", 5, 200, "", "")
+}
+
+func TestResponseWithoutBody(t *testing.T) {
+	for _, status := range []string{"204 No Content", "304 Not Modified"} {
+		protocol := ResponseProtocol{}
+		parser := httpparser.NewHTTPResponseParser(&protocol, httpparser.Settings{})
+		response := "HTTP/1.1 " + status + "\r\nContent-Length: 13\r\n\r\n"
+
+		if err := parser.Feed([]byte(response)); err != nil {
+			t.Fatalf("%s: unexpected error: %s", status, err)
+		} else if protocol.CompletedTimes != 1 {
+			t.Fatalf("%s: no completion flag", status)
+		}
+	}
+}
+
+func TestResponseToHEADRequest(t *testing.T) {
+	protocol := ResponseProtocol{}
+	parser := httpparser.NewHTTPResponseParser(&protocol, httpparser.Settings{})
+	parser.SetRequestMethod(httpparser.HEAD)
+
+	response := "HTTP/1.1 200 OK\r\nContent-Length: 13\r\n\r\n" +
+		"HTTP/1.1 200 OK\r\nContent-Length: 13\r\n\r\nHello, world!"
+
+	if err := parser.Feed([]byte(response)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if protocol.CompletedTimes != 2 {
+		t.Fatalf("wanted 2 completions, got %d", protocol.CompletedTimes)
+	} else if string(protocol.Body) != "Hello, world!" {
+		t.Fatalf("only second response must have a body, got %s", quote(protocol.Body))
+	}
+}
+
+func TestResponseInformational(t *testing.T) {
+	protocol := ResponseProtocol{}
+	parser := httpparser.NewHTTPResponseParser(&protocol, httpparser.Settings{})
+
+	response := "HTTP/1.1 100 Continue\r\n\r\nHTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok"
+
+	if err := FeedParser(parser, []byte(response), 3); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if protocol.CompletedTimes != 2 {
+		t.Fatalf("wanted 2 completions, got %d", protocol.CompletedTimes)
+	} else if protocol.StatusCode != 200 || string(protocol.Body) != "ok" {
+		t.Fatalf("unexpected final response: %d %s", protocol.StatusCode, quote(protocol.Body))
+	}
+}
+
+func TestResponseConnectionCloseDelimited(t *testing.T) {
+	protocol := ResponseProtocol{}
+	parser := httpparser.NewHTTPResponseParser(&protocol, httpparser.Settings{})
+
+	body := "Hello, I have a body for you!"
+	response := []byte("HTTP/1.0 200 OK\r\nServer: indigo\r\n\r\n" + body)
+
+	if err := FeedParser(parser, response, 5); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if protocol.CompletedTimes != 0 {
+		t.Fatal("got unexpected completion flag")
+	}
+
+	if err := parser.Feed(nil); err != httpparser.ErrConnectionClosed {
+		t.Fatalf("expected ErrConnectionClosed error, got %v", err)
+	} else if protocol.CompletedTimes != 1 {
+		t.Fatal("no completion flag")
+	} else if string(protocol.Body) != body {
+		t.Fatalf("wanted body %q, got %s", body, quote(protocol.Body))
+	}
+}
+
+func TestResponseInvalidStatusCode(t *testing.T) {
+	for _, response := range []string{
+		"HTTP/1.1 20 OK\r\n\r\n",
+		"HTTP/1.1 2000 OK\r\n\r\n",
+		"HTTP/1.1 0200 OK\r\n\r\n",
+		"HTTP/1.1 2x0 OK\r\n\r\n",
+	} {
+		parser := httpparser.NewHTTPResponseParser(&ResponseProtocol{}, httpparser.Settings{})
+
+		if err := parser.Feed([]byte(response)); !errors.Is(err, httpparser.ErrInvalidStatusCode) {
+			t.Fatalf("%q: expected ErrInvalidStatusCode, got %v", response, err)
+		}
+	}
+}
+
+func TestResponseSwitchingProtocols(t *testing.T) {
+	protocol := ResponseProtocol{}
+	parser := httpparser.NewHTTPResponseParser(&protocol, httpparser.Settings{})
+	response := "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n\x81\x05hello"
+
+	upgrade, ok := parser.Feed([]byte(response)).(httpparser.Upgrade)
+
+	if !ok {
+		t.Fatal("wanted Upgrade")
+	} else if protocol.CompletedTimes != 1 {
+		t.Fatalf("wanted 1 completion, got %d", protocol.CompletedTimes)
+	} else if strings.Join(upgrade.Protocols, "|") != "websocket" {
+		t.Fatalf("unexpected protocols: %q", upgrade.Protocols)
+	} else if string(upgrade.Rest) != "\x81\x05hello" {
+		t.Fatalf("unexpected rest: %q", upgrade.Rest)
+	}
+}
+
+func TestResponseToCONNECTRequest(t *testing.T) {
+	protocol := ResponseProtocol{}
+	parser := httpparser.NewHTTPResponseParser(&protocol, httpparser.Settings{})
+	parser.SetRequestMethod(httpparser.CONNECT)
+	response := "HTTP/1.1 200 Connection Established\r\nContent-Length: 13\r\n\r\n\x16\x03\x01"
+
+	upgrade, ok := parser.Feed([]byte(response)).(httpparser.Upgrade)
+
+	if !ok {
+		t.Fatal("wanted Upgrade")
+	} else if len(upgrade.Protocols) != 0 {
+		t.Fatalf("unexpected protocols: %q", upgrade.Protocols)
+	} else if string(upgrade.Rest) != "\x16\x03\x01" {
+		t.Fatalf("unexpected rest: %q", upgrade.Rest)
+	} else if len(protocol.Body) != 0 {
+		t.Fatalf("tunnel data must not be a body, got %s", quote(protocol.Body))
+	}
+}
+
+func TestResponseInvalidProtocol(t *testing.T) {
+	for _, response := range []string{
+		"\x81\x05hello",
+		"HTTP/1.1\r\n\r\n",
+		"HTTP/2.0 200 OK\r\n\r\n",
+	} {
+		parser := httpparser.NewHTTPResponseParser(&ResponseProtocol{}, httpparser.Settings{})
+
+		if err := parser.Feed([]byte(response)); !errors.Is(err, httpparser.ErrProtocolNotSupported) {
+			t.Fatalf("%q: expected ErrProtocolNotSupported, got %v", response, err)
+		}
+	}
+}
+
+func TestResponseReasonOverflow(t *testing.T) {
+	parser := httpparser.NewHTTPResponseParser(&ResponseProtocol{}, httpparser.Settings{})
+	response := "HTTP/1.1 200 " + strings.Repeat("O", 1024) + "\r\n\r\n"
+
+	if err := parser.Feed([]byte(response)); !errors.Is(err, httpparser.ErrReasonOverflow) {
+		t.Fatalf("expected ErrReasonOverflow, got %v", err)
+	}
+}
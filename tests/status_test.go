@@ -0,0 +1,73 @@
+package httpparser
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/fakefloordiv/snowdrop-http/httpparser"
+)
+
+func TestErrorStatusCode(t *testing.T) {
+	longPath := "/" + strings.Repeat("a", 5000)
+	longHeader := "X-Long: " + strings.Repeat("a", 5000)
+
+	for _, tc := range []struct {
+		request string
+		status  int
+	}{
+		{"GET " + longPath + " HTTP/1.1\r\n\r\n", 414},
+		{"GET / HTTP/1.1\r\n" + longHeader + "\r\n\r\n", 431},
+		{"POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\nfffff\r\n", 413},
+		{"BREW / HTTP/1.1\r\n\r\n", 501},
+		{"GE(T / HTTP/1.1\r\n\r\n", 400},
+		{"VERYLONGMETHOD / HTTP/1.1\r\n\r\n", 400},
+		{"POST / HTTP/1.1\r\nTransfer-Encoding: br, chunked\r\n\r\n", 501},
+		{"GET / HTTP/2.0\r\n\r\n", 505},
+		{"GET / HTTP/1.1111111\r\n\r\n", 400},
+		{"GET / HTTP/1.1\r\nHost\x01: rush.dev\r\n\r\n", 400},
+	} {
+		parser, _ := httpparser.NewHTTPRequestParser(&Protocol{}, httpparser.Settings{})
+		err := parser.Feed([]byte(tc.request))
+
+		if err == nil {
+			t.Fatalf("%.30q: expected error", tc.request)
+		} else if status := httpparser.ErrorStatusCode(err); status != tc.status {
+			t.Fatalf("%.30q: wanted status %d, got %d (%s)", tc.request, tc.status, status, err)
+		}
+	}
+}
+
+func TestBufferOverflowVariants(t *testing.T) {
+	for _, err := range []error{httpparser.ErrPathOverflow, httpparser.ErrHeaderOverflow, httpparser.ErrProtocolOverflow,
+		httpparser.ErrReasonOverflow,
+	} {
+		if !errors.Is(err, httpparser.ErrBufferOverflow) {
+			t.Fatalf("%s must be ErrBufferOverflow", err)
+		}
+	}
+}
+
+func TestWriteErrorResponse(t *testing.T) {
+	var buff bytes.Buffer
+
+	if err := httpparser.WriteErrorResponse(&buff, httpparser.ErrPathOverflow); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := "HTTP/1.1 414 URI Too Long\r\nContent-Length: 0\r\nConnection: close\r\n\r\n"
+
+	if buff.String() != want {
+		t.Fatalf("wanted %q, got %q", want, buff.String())
+	}
+
+	protocol := ResponseProtocol{}
+	parser := httpparser.NewHTTPResponseParser(&protocol, httpparser.Settings{})
+
+	if err := parser.Feed(buff.Bytes()); err != nil {
+		t.Fatalf("response must be valid, got %s", err)
+	} else if protocol.StatusCode != 414 || parser.ShouldKeepAlive() {
+		t.Fatalf("unexpected response: %d, keep-alive=%t", protocol.StatusCode, parser.ShouldKeepAlive())
+	}
+}
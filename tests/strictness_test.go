@@ -0,0 +1,172 @@
+package httpparser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fakefloordiv/snowdrop-http/httpparser"
+)
+
+func TestStrictnessRejections(t *testing.T) {
+	for _, tc := range []struct {
+		request    string
+		strictness httpparser.Strictness
+		err        error
+	}{
+		{"GET / HTTP/1.1\nHost: rush.dev\r\n\r\n", httpparser.RejectBareLF, httpparser.ErrBareLF},
+		{"GET / HTTP/1.1\r\nHost: rush.dev\n\r\n", httpparser.RejectBareLF, httpparser.ErrBareLF},
+		{"GET / HTTP/1.1\r\nHost: rush.dev\r\n\n", httpparser.RejectBareLF, httpparser.ErrBareLF},
+		{"GET / HTTP/1.1\rHost: rush.dev\r\n\r\n", 0, httpparser.ErrBareCR},
+		{"GET / HTTP/1.1\r\nHost: rush\r.dev\r\n\r\n", 0, httpparser.ErrBareCR},
+		{"GET / HTTP/1.1\r\nHost: rush.dev\r\n\rX", 0, httpparser.ErrBareCR},
+		{"GET / HTTP/1.1\r\nHost : rush.dev\r\n\r\n", httpparser.RejectWhitespaceBeforeColon, httpparser.ErrWhitespaceBeforeColon},
+		{"GET / HTTP/1.1\r\nX-Long: a\r\n b\r\n\r\n", 0, httpparser.ErrObsFold},
+		{"GET / HTTP/1.1\r\nX-Long: a\r\n\tb\r\n\r\n", 0, httpparser.ErrObsFold},
+		{"GET / http/1.1\r\n\r\n", httpparser.RejectLowercaseProtocol, httpparser.ErrLowercaseProtocol},
+		{"GET  / HTTP/1.1\r\n\r\n", 0, httpparser.ErrInvalidPath},
+		{"GET /  HTTP/1.1\r\n\r\n", 0, httpparser.ErrMultipleSpaces},
+		{"POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n5\nhello\r\n0\r\n\r\n", httpparser.RejectBareLF, httpparser.ErrBareLF},
+		{"POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n5;a=b\nhello\r\n0\r\n\r\n", httpparser.RejectBareLF, httpparser.ErrBareLF},
+		{"POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\n0\r\n\r\n", httpparser.RejectBareLF, httpparser.ErrBareLF},
+	} {
+		for i := 1; i <= len(tc.request); i++ {
+			parser, _ := httpparser.NewHTTPRequestParser(&Protocol{}, httpparser.Settings{Strictness: tc.strictness})
+			err := FeedParser(parser, []byte(tc.request), i)
+
+			var parseErr *httpparser.ParseError
+
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("%q by %d: expected %v, got %v", tc.request, i, tc.err, err)
+			} else if !errors.As(err, &parseErr) {
+				t.Fatalf("%q by %d: expected ParseError, got %v", tc.request, i, err)
+			}
+		}
+	}
+}
+
+func TestStrictnessDefaults(t *testing.T) {
+	// everything that is rejected by flags above is accepted by default
+	for _, request := range []string{
+		"GET / HTTP/1.1\nHost: rush.dev\n\n",
+		"GET / http/1.1\r\nHost : rush.dev\r\n\r\n",
+		"POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n5;a=b\nhello\n0\n\n",
+	} {
+		parser, _ := httpparser.NewHTTPRequestParser(&Protocol{}, httpparser.Settings{})
+
+		if err := parser.Feed([]byte(request)); err != nil {
+			t.Fatalf("%q: unexpected error: %s", request, err)
+		}
+	}
+}
+
+func TestStrictnessLenient(t *testing.T) {
+	request := []byte("GET   /index.html   HTTP/1.1\r\nHost:   rush.dev \t\r\nX-Broken: a\rb\r\n\r\n")
+
+	for i := 1; i <= len(request); i++ {
+		var requests int
+
+		protocol := httpparser.NewRequestProtocol(func(request *httpparser.Request) error {
+			requests++
+			host, _ := request.Header.Get("Host")
+			broken, _ := request.Header.Get("X-Broken")
+
+			switch {
+			case string(request.Path()) != "/index.html":
+				t.Fatalf("feeding by %d: unexpected path: %s", i, quote(request.Path()))
+			case string(request.Protocol()) != "HTTP/1.1":
+				t.Fatalf("feeding by %d: unexpected protocol: %s", i, quote(request.Protocol()))
+			case string(host) != "rush.dev":
+				t.Fatalf("feeding by %d: value must be trimmed, got %s", i, quote(host))
+			case string(broken) != "a b":
+				t.Fatalf("feeding by %d: bare CR must be replaced, got %s", i, quote(broken))
+			}
+
+			return nil
+		})
+		parser, _ := httpparser.NewHTTPRequestParser(protocol, httpparser.Settings{Strictness: httpparser.Lenient})
+
+		if err := FeedParser(parser, request, i); err != nil {
+			t.Fatalf("feeding by %d: unexpected error: %s", i, err)
+		} else if requests != 1 {
+			t.Fatalf("feeding by %d: request is not completed", i)
+		}
+	}
+}
+
+func TestStrictnessStrict(t *testing.T) {
+	protocol := Protocol{}
+	parser, _ := httpparser.NewHTTPRequestParser(&protocol, httpparser.Settings{Strictness: httpparser.Strict})
+
+	if err := parser.Feed([]byte("GET / HTTP/1.1\r\nHost: \t rush.dev \t\r\n\r\n")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if string(protocol.Headers["Host"]) != "rush.dev" {
+		t.Fatalf("value must be trimmed, got %s", quote(protocol.Headers["Host"]))
+	}
+}
+
+func TestStrictnessEmptyHeaderValue(t *testing.T) {
+	for _, strictness := range []httpparser.Strictness{0, httpparser.Strict, httpparser.Lenient} {
+		protocol := Protocol{}
+		parser, _ := httpparser.NewHTTPRequestParser(&protocol, httpparser.Settings{Strictness: strictness})
+
+		if err := FeedParser(parser, []byte("GET / HTTP/1.1\r\nX-Empty:\r\nX-Spaces: \r\nHost: rush.dev\r\n\r\n"), 1); err != nil {
+			t.Fatalf("strictness %d: unexpected error: %s", strictness, err)
+		} else if value, found := protocol.Headers["X-Empty"]; !found || len(value) != 0 {
+			t.Fatalf("strictness %d: wanted empty value, got %s", strictness, quote(value))
+		} else if string(protocol.Headers["Host"]) != "rush.dev" {
+			t.Fatalf("strictness %d: unexpected host: %s", strictness, quote(protocol.Headers["Host"]))
+		}
+
+		response := "HTTP/1.1 200 OK\r\nX-Empty:\r\nContent-Length: 0\r\n\r\n"
+		responseParser := httpparser.NewHTTPResponseParser(&ResponseProtocol{}, httpparser.Settings{Strictness: strictness})
+
+		if err := responseParser.Feed([]byte(response)); err != nil {
+			t.Fatalf("strictness %d: response: unexpected error: %s", strictness, err)
+		}
+	}
+}
+
+func TestStrictnessLeadingWhitespace(t *testing.T) {
+	for _, strictness := range []httpparser.Strictness{0, httpparser.Strict, httpparser.Lenient} {
+		for _, header := range []string{"X: v", "X:\tv", "X: \t v", "X:v"} {
+			protocol := Protocol{}
+			parser, _ := httpparser.NewHTTPRequestParser(&protocol, httpparser.Settings{Strictness: strictness})
+			request := "GET / HTTP/1.1\r\n" + header + "\r\n\r\n"
+
+			if err := FeedParser(parser, []byte(request), 1); err != nil {
+				t.Fatalf("strictness %d, %q: unexpected error: %s", strictness, header, err)
+			} else if string(protocol.Headers["X"]) != "v" {
+				t.Fatalf("strictness %d, %q: wanted \"v\", got %s", strictness, header, quote(protocol.Headers["X"]))
+			}
+		}
+	}
+}
+
+func TestStrictnessLowercaseProtocolStatus(t *testing.T) {
+	parser, _ := httpparser.NewHTTPRequestParser(&Protocol{}, httpparser.Settings{Strictness: httpparser.Strict})
+	err := parser.Feed([]byte("GET / http/1.1\r\n\r\n"))
+
+	if !errors.Is(err, httpparser.ErrRequestSyntaxError) {
+		t.Fatalf("expected ErrRequestSyntaxError, got %v", err)
+	} else if status := httpparser.ErrorStatusCode(err); status != 400 {
+		t.Fatalf("wanted status 400, got %d", status)
+	}
+}
+
+func TestStrictnessResponse(t *testing.T) {
+	for _, tc := range []struct {
+		response string
+		err      error
+	}{
+		{"HTTP/1.1 200 OK\nContent-Length: 0\r\n\r\n", httpparser.ErrBareLF},
+		{"HTTP/1.1 200\nContent-Length: 0\r\n\r\n", httpparser.ErrBareLF},
+		{"http/1.1 200 OK\r\nContent-Length: 0\r\n\r\n", httpparser.ErrLowercaseProtocol},
+		{"HTTP/1.1 200 OK\r\nContent-Length: 0\n\r\n", httpparser.ErrBareLF},
+	} {
+		parser := httpparser.NewHTTPResponseParser(&ResponseProtocol{}, httpparser.Settings{Strictness: httpparser.Strict})
+
+		if err := parser.Feed([]byte(tc.response)); !errors.Is(err, tc.err) {
+			t.Fatalf("%q: expected %v, got %v", tc.response, tc.err, err)
+		}
+	}
+}
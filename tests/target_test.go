@@ -0,0 +1,133 @@
+package httpparser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fakefloordiv/snowdrop-http/httpparser"
+)
+
+type TargetProtocol struct {
+	Protocol
+	URLPath  []byte
+	Query    []byte
+	Fragment []byte
+	Scheme   []byte
+	Host     []byte
+	Port     []byte
+}
+
+func (p *TargetProtocol) OnURLPath(path []byte) error {
+	p.URLPath = append([]byte(nil), path...)
+
+	return nil
+}
+
+func (p *TargetProtocol) OnQueryString(query []byte) error {
+	p.Query = append([]byte{}, query...)
+
+	return nil
+}
+
+func (p *TargetProtocol) OnFragment(fragment []byte) error {
+	p.Fragment = append([]byte{}, fragment...)
+
+	return nil
+}
+
+func (p *TargetProtocol) OnAbsoluteTarget(scheme, host, port []byte) error {
+	p.Scheme = append([]byte(nil), scheme...)
+	p.Host = append([]byte(nil), host...)
+	p.Port = append([]byte(nil), port...)
+
+	return nil
+}
+
+func TestRequestTargetForms(t *testing.T) {
+	for _, tc := range []struct {
+		request string
+		form    httpparser.RequestTargetForm
+	}{
+		{"GET /index.html HTTP/1.1\r\n\r\n", httpparser.OriginForm},
+		{"GET http://rush.dev/index.html HTTP/1.1\r\n\r\n", httpparser.AbsoluteForm},
+		{"CONNECT rush.dev:443 HTTP/1.1\r\n\r\n", httpparser.AuthorityForm},
+		{"OPTIONS * HTTP/1.1\r\n\r\n", httpparser.AsteriskForm},
+	} {
+		parser, _ := httpparser.NewHTTPRequestParser(&Protocol{}, httpparser.Settings{})
+
+		// CONNECT request returns Upgrade, but that's not what we are testing here
+		_ = parser.Feed([]byte(tc.request))
+
+		if form := parser.RequestTargetForm(); form != tc.form {
+			t.Fatalf("%q: wanted form %d, got %d", tc.request, tc.form, form)
+		}
+	}
+}
+
+func TestRequestTargetDecomposition(t *testing.T) {
+	for _, tc := range []struct {
+		target, path, query, fragment string
+		hasQuery, hasFragment         bool
+	}{
+		{"/", "/", "", "", false, false},
+		{"/search?q=hello&lang=en", "/search", "q=hello&lang=en", "", true, false},
+		{"/page#section", "/page", "", "section", false, true},
+		{"/page?#", "/page", "", "", true, true},
+		{"/page?a=b#c?d", "/page", "a=b", "c?d", true, true},
+		{"/page#c?d", "/page", "", "c?d", false, true},
+	} {
+		request := []byte("GET " + tc.target + " HTTP/1.1\r\n\r\n")
+
+		for i := 1; i <= len(request); i++ {
+			protocol := TargetProtocol{}
+			parser, _ := httpparser.NewHTTPRequestParser(&protocol, httpparser.Settings{})
+
+			switch err := FeedParser(parser, request, i); {
+			case err != nil:
+				t.Fatalf("%q by %d: unexpected error: %s", tc.target, i, err)
+			case string(protocol.URLPath) != tc.path:
+				t.Fatalf("%q by %d: wanted path %q, got %s", tc.target, i, tc.path, quote(protocol.URLPath))
+			case string(protocol.Query) != tc.query || (protocol.Query != nil) != tc.hasQuery:
+				t.Fatalf("%q by %d: wanted query %q, got %s", tc.target, i, tc.query, quote(protocol.Query))
+			case string(protocol.Fragment) != tc.fragment || (protocol.Fragment != nil) != tc.hasFragment:
+				t.Fatalf("%q by %d: wanted fragment %q, got %s", tc.target, i, tc.fragment, quote(protocol.Fragment))
+			case string(protocol.Path) != tc.target:
+				t.Fatalf("%q by %d: OnPath must receive the whole target, got %s", tc.target, i, quote(protocol.Path))
+			}
+		}
+	}
+}
+
+func TestAbsoluteFormTarget(t *testing.T) {
+	for _, tc := range []struct {
+		target, scheme, host, port, path, query string
+	}{
+		{"http://rush.dev/index.html", "http", "rush.dev", "", "/index.html", ""},
+		{"https://rush.dev:8443/a?b=c", "https", "rush.dev", "8443", "/a", "b=c"},
+		{"http://[::1]:8080", "http", "::1", "8080", "/", ""},
+		{"http://rush.dev?x=1", "http", "rush.dev", "", "/", "x=1"},
+	} {
+		protocol := TargetProtocol{}
+		parser, _ := httpparser.NewHTTPRequestParser(&protocol, httpparser.Settings{})
+
+		switch err := parser.Feed([]byte("GET " + tc.target + " HTTP/1.1\r\n\r\n")); {
+		case err != nil:
+			t.Fatalf("%q: unexpected error: %s", tc.target, err)
+		case string(protocol.Scheme) != tc.scheme || string(protocol.Host) != tc.host || string(protocol.Port) != tc.port:
+			t.Fatalf("%q: unexpected scheme, host or port: %s %s %s",
+				tc.target, quote(protocol.Scheme), quote(protocol.Host), quote(protocol.Port))
+		case string(protocol.URLPath) != tc.path || string(protocol.Query) != tc.query:
+			t.Fatalf("%q: unexpected path or query: %s %s", tc.target, quote(protocol.URLPath), quote(protocol.Query))
+		}
+	}
+}
+
+func TestInvalidRequestTarget(t *testing.T) {
+	for _, target := range []string{"index.html", "http:/rush.dev", "://rush.dev", "1http://rush.dev", "http://", "http://user@rush.dev/", "*"} {
+		parser, _ := httpparser.NewHTTPRequestParser(&TargetProtocol{}, httpparser.Settings{})
+
+		if err := parser.Feed([]byte("GET " + target + " HTTP/1.1\r\n\r\n")); !errors.Is(err, httpparser.ErrInvalidPath) {
+			t.Fatalf("%q: expected ErrInvalidPath, got %v", target, err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package httpparser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/fakefloordiv/snowdrop-http/httpparser"
+)
+
+type TunnelProtocol struct {
+	Protocol
+	Host   []byte
+	Port   []byte
+	Tunnel []byte
+}
+
+func (p *TunnelProtocol) OnAuthority(host, port []byte) error {
+	p.Host = append(p.Host[:0], host...)
+	p.Port = append(p.Port[:0], port...)
+
+	return nil
+}
+
+func (p *TunnelProtocol) OnTunnelData(data []byte) error {
+	p.Tunnel = append(p.Tunnel, data...)
+
+	return nil
+}
+
+func TestConnectTunnel(t *testing.T) {
+	request := []byte("CONNECT rush.dev:443 HTTP/1.1\r\nHost: rush.dev:443\r\n\r\n" +
+		"\x16\x03\x01GET / HTTP/1.1\r\n\r\n")
+
+	for i := 1; i <= len(request); i++ {
+		protocol := TunnelProtocol{}
+		parser, _ := httpparser.NewHTTPRequestParser(&protocol, httpparser.Settings{})
+
+		if err := FeedParser(parser, request, i); err != nil {
+			t.Fatalf("feeding by %d: unexpected error: %s", i, err)
+		} else if protocol.CompletedTimes != 1 {
+			t.Fatalf("feeding by %d: wanted 1 completion, got %d", i, protocol.CompletedTimes)
+		} else if string(protocol.Host) != "rush.dev" || string(protocol.Port) != "443" {
+			t.Fatalf("feeding by %d: unexpected authority: %s %s", i, quote(protocol.Host), quote(protocol.Port))
+		} else if string(protocol.Tunnel) != "\x16\x03\x01GET / HTTP/1.1\r\n\r\n" {
+			t.Fatalf("feeding by %d: unexpected tunnel data: %s", i, quote(protocol.Tunnel))
+		} else if parser.ShouldKeepAlive() {
+			t.Fatalf("feeding by %d: tunneled connection must not be kept alive", i)
+		}
+
+		if err := parser.Feed(nil); err != httpparser.ErrConnectionClosed {
+			t.Fatalf("feeding by %d: expected ErrConnectionClosed, got %v", i, err)
+		}
+	}
+}
+
+func TestConnectAuthority(t *testing.T) {
+	for _, tc := range []struct {
+		target, host, port string
+	}{
+		{"rush.dev:443", "rush.dev", "443"},
+		{"127.0.0.1:8080", "127.0.0.1", "8080"},
+		{"[::1]:443", "::1", "443"},
+	} {
+		protocol := TunnelProtocol{}
+		parser, _ := httpparser.NewHTTPRequestParser(&protocol, httpparser.Settings{})
+
+		if err := parser.Feed([]byte("CONNECT " + tc.target + " HTTP/1.1\r\n\r\n")); err != nil {
+			t.Fatalf("%q: unexpected error: %s", tc.target, err)
+		} else if string(protocol.Host) != tc.host || string(protocol.Port) != tc.port {
+			t.Fatalf("%q: unexpected authority: %s %s", tc.target, quote(protocol.Host), quote(protocol.Port))
+		}
+	}
+}
+
+func TestConnectInvalidAuthority(t *testing.T) {
+	for _, target := range []string{"rush.dev", "rush.dev:", ":443", "rush.dev:https", "::1:443", "/index.html"} {
+		parser, _ := httpparser.NewHTTPRequestParser(&TunnelProtocol{}, httpparser.Settings{})
+
+		if err := parser.Feed([]byte("CONNECT " + target + " HTTP/1.1\r\n\r\n")); !errors.Is(err, httpparser.ErrInvalidAuthority) {
+			t.Fatalf("%q: expected ErrInvalidAuthority, got %v", target, err)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package httpparser
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/fakefloordiv/snowdrop-http/httpparser"
@@ -96,3 +97,97 @@ func TestUpgrade(t *testing.T) {
 		t.Fatal("Wanted nil, got", err)
 	}
 }
+
+func TestUpgradeDetection(t *testing.T) {
+	protocol := Protocol{}
+	parser, _ := httpparser.NewHTTPRequestParser(&protocol, httpparser.Settings{})
+
+	request := []byte("GET /chat HTTP/1.1\r\nHost: rush.dev\r\nConnection: keep-alive, Upgrade\r\n" +
+		"Upgrade: websocket\r\nUpgrade: foo/2, bar\r\n\r\n\x81\x05hello")
+
+	upgrade, ok := parser.Feed(request).(httpparser.Upgrade)
+
+	if !ok {
+		t.Fatal("wanted Upgrade")
+	} else if !protocol.Completed {
+		t.Fatal("no completion flag")
+	} else if strings.Join(upgrade.Protocols, "|") != "websocket|foo/2|bar" {
+		t.Fatalf("unexpected protocols: %q", upgrade.Protocols)
+	} else if string(upgrade.Rest) != "\x81\x05hello" {
+		t.Fatalf("unexpected rest: %q", upgrade.Rest)
+	}
+}
+
+func TestUpgradeConnect(t *testing.T) {
+	parser, _ := httpparser.NewHTTPRequestParser(&Protocol{}, httpparser.Settings{})
+	request := []byte("CONNECT rush.dev:443 HTTP/1.1\r\nHost: rush.dev:443\r\n\r\n\x16\x03\x01")
+
+	upgrade, ok := parser.Feed(request).(httpparser.Upgrade)
+
+	if !ok {
+		t.Fatal("wanted Upgrade")
+	} else if len(upgrade.Protocols) != 0 {
+		t.Fatalf("unexpected protocols: %q", upgrade.Protocols)
+	} else if string(upgrade.Rest) != "\x16\x03\x01" {
+		t.Fatalf("unexpected rest: %q", upgrade.Rest)
+	}
+}
+
+func TestUpgradeAfterBody(t *testing.T) {
+	for _, request := range []string{
+		"POST / HTTP/1.1\r\nConnection: Upgrade, HTTP2-Settings\r\nUpgrade: h2c\r\nContent-Length: 5\r\n\r\nhello",
+		"POST / HTTP/1.1\r\nConnection: upgrade\r\nUpgrade: h2c\r\nTransfer-Encoding: chunked\r\n\r\n" +
+			"5\r\nhello\r\n0\r\n\r\n",
+	} {
+		protocol := Protocol{}
+		parser, _ := httpparser.NewHTTPRequestParser(&protocol, httpparser.Settings{})
+		upgrade, ok := parser.Feed([]byte(request + "PRI * HTTP/2.0")).(httpparser.Upgrade)
+
+		if !ok {
+			t.Fatalf("%q: wanted Upgrade", request)
+		} else if string(protocol.Body) != "hello" {
+			t.Fatalf("%q: body must be parsed before upgrade, got %s", request, quote(protocol.Body))
+		} else if protocol.CompletedTimes != 1 {
+			t.Fatalf("%q: wanted 1 completion, got %d", request, protocol.CompletedTimes)
+		} else if strings.Join(upgrade.Protocols, "|") != "h2c" {
+			t.Fatalf("%q: unexpected protocols: %q", request, upgrade.Protocols)
+		} else if string(upgrade.Rest) != "PRI * HTTP/2.0" {
+			t.Fatalf("%q: unexpected rest: %q", request, upgrade.Rest)
+		}
+	}
+}
+
+func TestNoUpgradeWithoutHeader(t *testing.T) {
+	for _, request := range []string{
+		"GET / HTTP/1.1\r\nConnection: upgrade\r\n\r\n",
+		"GET / HTTP/1.1\r\nUpgrade: websocket\r\n\r\n",
+	} {
+		protocol := Protocol{}
+		parser, _ := httpparser.NewHTTPRequestParser(&protocol, httpparser.Settings{})
+
+		if err := parser.Feed([]byte(request)); err != nil {
+			t.Fatalf("%q: unexpected error: %s", request, err)
+		} else if !protocol.Completed {
+			t.Fatalf("%q: no completion flag", request)
+		}
+	}
+}
+
+func TestUpgradeFromCallbackReturnsRest(t *testing.T) {
+	for _, request := range []string{
+		"POST / HTTP/1.1\r\nContent-Length: 5\r\n\r\nhello",
+		"POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n0\r\n\r\n",
+		"GET / HTTP/1.1\r\n\r\n",
+	} {
+		parser, _ := httpparser.NewHTTPRequestParser(&protocol{}, httpparser.Settings{})
+		upgrade, ok := parser.Feed([]byte(request + "\x81\x05hello")).(httpparser.Upgrade)
+
+		if !ok {
+			t.Fatalf("%q: wanted Upgrade", request)
+		} else if string(upgrade.Rest) != "\x81\x05hello" {
+			t.Fatalf("%q: unexpected rest: %q", request, upgrade.Rest)
+		} else if strings.Join(upgrade.Protocols, "|") != "http/2|http/3" {
+			t.Fatalf("%q: unexpected protocols: %q", request, upgrade.Protocols)
+		}
+	}
+}